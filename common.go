@@ -6,7 +6,10 @@ import (
 )
 
 type NotifyFunc func(method string, params any)
-type CallFunc func(method string, params any, result any)
+
+// If context is nil the context of the current request will be used.
+// Errors returned by the client will be of type *Error.
+type CallFunc func(context contextpkg.Context, method string, params any, result any) error
 
 type Context struct {
 	Method  string
@@ -14,6 +17,15 @@ type Context struct {
 	Notify  NotifyFunc
 	Call    CallFunc
 	Context contextpkg.Context // can be nil
+	Session *Session           // can be nil
+}
+
+// Returns a shallow copy with a different [contextpkg.Context], e.g. in order
+// to set a timeout for calls to the client.
+func (self *Context) WithContext(context contextpkg.Context) *Context {
+	context_ := *self
+	context_.Context = context
+	return &context_
 }
 
 type Handler interface {
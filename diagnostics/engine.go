@@ -0,0 +1,159 @@
+package diagnostics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/tliron/glsp"
+	"github.com/tliron/glsp/partial"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+	protocol317 "github.com/tliron/glsp/protocol_3_17"
+)
+
+//
+// Report
+//
+
+type Report struct {
+	URI         protocol.DocumentUri
+	Version     *protocol.Integer // nil if unknown or the document is not open
+	Diagnostics []protocol.Diagnostic
+}
+
+type DiagnoseFunc func(context *glsp.Context, uri protocol.DocumentUri) (diagnostics []protocol.Diagnostic, related []*Report, err error)
+
+// Should call the report func for every document in the workspace, and stop
+// if it returns an error.
+type DiagnoseWorkspaceFunc func(context *glsp.Context, report func(report *Report) error) error
+
+//
+// Engine
+//
+
+// Answers pull diagnostics requests ("textDocument/diagnostic" and
+// "workspace/diagnostic"). Result IDs are hashes of the diagnostics, so that
+// we can respond with "unchanged" reports without keeping state.
+type Engine struct {
+	// Required for "textDocument/diagnostic". The related reports may be nil,
+	// and are ignored if the client does not support them.
+	Diagnose DiagnoseFunc
+
+	// Required for "workspace/diagnostic". Reports are streamed if the
+	// client provided a partial result token.
+	DiagnoseWorkspace DiagnoseWorkspaceFunc
+}
+
+// Sets the handler's pull diagnostics funcs for the engine's non-nil funcs.
+func (self *Engine) Register(handler *protocol317.Handler) {
+	if self.Diagnose != nil {
+		handler.TextDocumentDiagnostic = self.TextDocumentDiagnostic
+	}
+	if self.DiagnoseWorkspace != nil {
+		handler.WorkspaceDiagnostic = self.WorkspaceDiagnostic
+	}
+}
+
+// ([protocol317.TextDocumentDiagnosticFunc] signature)
+func (self *Engine) TextDocumentDiagnostic(context *glsp.Context, params *protocol317.DocumentDiagnosticParams) (any, error) {
+	diagnostics, related, err := self.Diagnose(context, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	var relatedDocuments map[protocol.DocumentUri]any
+	if (len(related) > 0) && supportsRelatedDocuments(context) {
+		relatedDocuments = make(map[protocol.DocumentUri]any)
+		for _, report := range related {
+			relatedDocuments[report.URI] = newFullReport(report.Diagnostics, ResultID(report.Diagnostics))
+		}
+	}
+
+	resultId := ResultID(diagnostics)
+	if (params.PreviousResultId != nil) && (*params.PreviousResultId == resultId) {
+		return &protocol317.RelatedUnchangedDocumentDiagnosticReport{
+			UnchangedDocumentDiagnosticReport: newUnchangedReport(resultId),
+			RelatedDocuments:                  relatedDocuments,
+		}, nil
+	} else {
+		return &protocol317.RelatedFullDocumentDiagnosticReport{
+			FullDocumentDiagnosticReport: newFullReport(diagnostics, resultId),
+			RelatedDocuments:             relatedDocuments,
+		}, nil
+	}
+}
+
+// ([protocol317.WorkspaceDiagnosticFunc] signature)
+func (self *Engine) WorkspaceDiagnostic(context *glsp.Context, params *protocol317.WorkspaceDiagnosticParams) (*protocol317.WorkspaceDiagnosticReport, error) {
+	previousResultIds := make(map[protocol.DocumentUri]string)
+	for _, previousResultId := range params.PreviousResultIds {
+		previousResultIds[previousResultId.URI] = previousResultId.Value
+	}
+
+	sink := partial.NewSink[protocol317.WorkspaceDocumentDiagnosticReport](context, params.PartialResultToken)
+	sink.Wrap = func(batch []protocol317.WorkspaceDocumentDiagnosticReport) any {
+		return &protocol317.WorkspaceDiagnosticReportPartialResult{Items: batch}
+	}
+
+	if err := self.DiagnoseWorkspace(context, func(report *Report) error {
+		resultId := ResultID(report.Diagnostics)
+		if previousResultId, ok := previousResultIds[report.URI]; ok && (previousResultId == resultId) {
+			return sink.Push(&protocol317.WorkspaceUnchangedDocumentDiagnosticReport{
+				UnchangedDocumentDiagnosticReport: newUnchangedReport(resultId),
+				URI:                               report.URI,
+				Version:                           report.Version,
+			})
+		} else {
+			return sink.Push(&protocol317.WorkspaceFullDocumentDiagnosticReport{
+				FullDocumentDiagnosticReport: newFullReport(report.Diagnostics, resultId),
+				URI:                          report.URI,
+				Version:                      report.Version,
+			})
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	return &protocol317.WorkspaceDiagnosticReport{Items: sink.Result()}, nil
+}
+
+// A hash of the diagnostics. Equal diagnostics (in the same order) have equal
+// result IDs.
+func ResultID(diagnostics []protocol.Diagnostic) string {
+	hash := sha256.New()
+	encoder := json.NewEncoder(hash)
+	for _, diagnostic := range diagnostics {
+		// Diagnostics are always marshallable, unless Data isn't
+		if err := encoder.Encode(diagnostic); err != nil {
+			hash.Write([]byte(diagnostic.Message))
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+func newFullReport(diagnostics []protocol.Diagnostic, resultId string) protocol317.FullDocumentDiagnosticReport {
+	if diagnostics == nil {
+		diagnostics = []protocol.Diagnostic{}
+	}
+	return protocol317.FullDocumentDiagnosticReport{
+		Kind:     string(protocol317.DocumentDiagnosticReportKindFull),
+		ResultID: &resultId,
+		Items:    diagnostics,
+	}
+}
+
+func newUnchangedReport(resultId string) protocol317.UnchangedDocumentDiagnosticReport {
+	return protocol317.UnchangedDocumentDiagnosticReport{
+		Kind:     string(protocol317.DocumentDiagnosticReportKindUnchanged),
+		ResultID: resultId,
+	}
+}
+
+func supportsRelatedDocuments(context *glsp.Context) bool {
+	if context.Session != nil {
+		if clientCapabilities, ok := context.Session.GetClientCapabilities().(*protocol317.ClientCapabilities); ok {
+			return (clientCapabilities.TextDocument != nil) && (clientCapabilities.TextDocument.Diagnostic != nil) && clientCapabilities.TextDocument.Diagnostic.RelatedDocumentSupport
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package diagnostics
+
+import (
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Wraps the handler's text document synchronization funcs so that the
+// publisher tracks document versions and clears diagnostics when documents
+// are closed (dropping late diagnostics set for the closed version).
+// The funcs may be nil. Must be called after the funcs are set.
+//
+// For protocol_3_17 use the embedded handler, e.g. publisher.Register(&handler.Handler).
+func (self *Publisher) Register(handler *protocol.Handler) {
+	register(handler, func(context *glsp.Context) *Publisher {
+		return self
+	})
+}
+
+// Like [Publisher.Register] but keeps a separate publisher for every session.
+// Use [SessionPublisher] to access it.
+func RegisterSessionPublishers(handler *protocol.Handler) {
+	register(handler, SessionPublisher)
+}
+
+type sessionPublisherKey struct{}
+
+var defaultPublisher = NewPublisher()
+
+// Returns the publisher for the context's session, creating it if necessary.
+// If the context has no session then a default publisher is shared.
+func SessionPublisher(context *glsp.Context) *Publisher {
+	if context.Session != nil {
+		return context.Session.GetOrCreate(sessionPublisherKey{}, func() any {
+			return NewPublisher()
+		}).(*Publisher)
+	} else {
+		return defaultPublisher
+	}
+}
+
+func register(handler *protocol.Handler, getPublisher func(context *glsp.Context) *Publisher) {
+	didOpen := handler.TextDocumentDidOpen
+	handler.TextDocumentDidOpen = func(context *glsp.Context, params *protocol.DidOpenTextDocumentParams) error {
+		getPublisher(context).Open(params.TextDocument.URI, params.TextDocument.Version)
+		if didOpen != nil {
+			return didOpen(context, params)
+		}
+		return nil
+	}
+
+	didChange := handler.TextDocumentDidChange
+	handler.TextDocumentDidChange = func(context *glsp.Context, params *protocol.DidChangeTextDocumentParams) error {
+		getPublisher(context).SetVersion(params.TextDocument.URI, params.TextDocument.Version)
+		if didChange != nil {
+			return didChange(context, params)
+		}
+		return nil
+	}
+
+	didClose := handler.TextDocumentDidClose
+	handler.TextDocumentDidClose = func(context *glsp.Context, params *protocol.DidCloseTextDocumentParams) error {
+		getPublisher(context).Clear(context, params.TextDocument.URI)
+		if didClose != nil {
+			return didClose(context, params)
+		}
+		return nil
+	}
+}
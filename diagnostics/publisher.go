@@ -0,0 +1,247 @@
+package diagnostics
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Used by [NewPublisher]
+var DefaultDelay = 250 * time.Millisecond
+
+//
+// Publisher
+//
+
+// Publishes "textDocument/publishDiagnostics" notifications. Diagnostics are
+// set separately for every source (e.g. a linter or a compiler) and are merged
+// when published. Publishing is debounced, so that rapid changes (e.g. while
+// typing) result in a single notification.
+type Publisher struct {
+	// Publishing of a document's diagnostics is delayed until none were set
+	// for this long. When zero or negative diagnostics are published
+	// immediately.
+	Delay time.Duration
+
+	documents map[protocol.DocumentUri]*document
+	lock      sync.Mutex
+}
+
+func NewPublisher() *Publisher {
+	return &Publisher{
+		Delay:     DefaultDelay,
+		documents: make(map[protocol.DocumentUri]*document),
+	}
+}
+
+// Replaces the diagnostics of the source for the document. The version should
+// be the document version for which the diagnostics were computed, or nil if
+// unknown. Returns false if the diagnostics were dropped because a newer
+// version of the document is known, or because they are for a version that
+// was closed (see [Publisher.Clear]).
+func (self *Publisher) Set(context *glsp.Context, uri protocol.DocumentUri, version *protocol.Integer, source string, diagnostics []protocol.Diagnostic) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	document, ok := self.getOpenDocument(uri, version)
+	if !ok {
+		return false
+	}
+
+	if version != nil {
+		if (document.version != nil) && (*version < *document.version) {
+			return false
+		}
+
+		// Copy, so that the caller cannot change it
+		version_ := *version
+		version = &version_
+		document.version = version
+	}
+
+	document.diagnosticsVersion = version
+	document.sources[source] = diagnostics
+	document.notify = context.Notify
+	document.versionSupport = supportsVersion(context)
+
+	document.generation++
+	if document.timer != nil {
+		document.timer.Stop()
+		document.timer = nil
+	}
+
+	if self.Delay > 0 {
+		generation := document.generation
+		document.timer = time.AfterFunc(self.Delay, func() {
+			self.lock.Lock()
+			defer self.lock.Unlock()
+
+			// Make sure the document was not cleared or set again
+			if (self.documents[uri] == document) && (document.generation == generation) {
+				document.timer = nil
+				document.publish(uri)
+			}
+		})
+	} else {
+		document.publish(uri)
+	}
+
+	return true
+}
+
+// Records the latest version of the document, so that diagnostics set for
+// older versions will be dropped. Ignored if it is not newer than the version
+// that was closed (see [Publisher.Clear]). See [Publisher.Register].
+func (self *Publisher) SetVersion(uri protocol.DocumentUri, version protocol.Integer) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	document, ok := self.getOpenDocument(uri, &version)
+	if !ok {
+		return
+	}
+
+	if (document.version == nil) || (version > *document.version) {
+		document.version = &version
+	}
+}
+
+// Starts tracking the document anew at the version, undoing [Publisher.Clear].
+// See [Publisher.Register].
+func (self *Publisher) Open(uri protocol.DocumentUri, version protocol.Integer) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if document, ok := self.documents[uri]; ok && document.closed {
+		delete(self.documents, uri)
+	}
+
+	self.getDocument(uri).version = &version
+}
+
+// Forgets the document's diagnostics and immediately publishes empty
+// diagnostics for it. If the document's version is known then diagnostics
+// that are later set for that version or older ones (e.g. by a late linter)
+// are dropped. Diagnostics set without a version or for a newer version, or
+// calling [Publisher.Open], start tracking the document anew. See
+// [Publisher.Register].
+func (self *Publisher) Clear(context *glsp.Context, uri protocol.DocumentUri) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	previous, ok := self.documents[uri]
+	delete(self.documents, uri)
+	if ok {
+		if previous.timer != nil {
+			previous.timer.Stop()
+		}
+		if previous.version != nil {
+			self.documents[uri] = &document{version: previous.version, closed: true}
+		}
+	}
+
+	// Nothing to clear if we never published
+	if ok && (previous.notify == nil) {
+		return
+	}
+
+	context.Notify(string(protocol.ServerTextDocumentPublishDiagnostics), &protocol.PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: []protocol.Diagnostic{},
+	})
+}
+
+// Immediately publishes all diagnostics that are waiting for the delay.
+func (self *Publisher) Flush() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	for uri, document := range self.documents {
+		if document.timer != nil {
+			document.timer.Stop()
+			document.timer = nil
+			document.publish(uri)
+		}
+	}
+}
+
+// Like [Publisher.getDocument] but forgets a closed document unless the
+// version is not newer than its closed version, in which case it returns
+// false. The version can be nil.
+//
+// Must be called while holding the lock
+func (self *Publisher) getOpenDocument(uri protocol.DocumentUri, version *protocol.Integer) (*document, bool) {
+	document := self.getDocument(uri)
+	if document.closed {
+		if (version != nil) && (*version <= *document.version) {
+			return nil, false
+		}
+		delete(self.documents, uri)
+		document = self.getDocument(uri)
+	}
+	return document, true
+}
+
+// Must be called while holding the lock
+func (self *Publisher) getDocument(uri protocol.DocumentUri) *document {
+	if document, ok := self.documents[uri]; ok {
+		return document
+	}
+
+	document := document{sources: make(map[string][]protocol.Diagnostic)}
+	self.documents[uri] = &document
+	return &document
+}
+
+//
+// document
+//
+
+type document struct {
+	version            *protocol.Integer // latest known version
+	diagnosticsVersion *protocol.Integer // version of the latest set diagnostics
+	sources            map[string][]protocol.Diagnostic
+	notify             glsp.NotifyFunc
+	versionSupport     bool
+	generation         uint64
+	timer              *time.Timer
+	closed             bool // only version is set, see Publisher.Clear
+}
+
+// Must be called while holding the lock
+func (self *document) publish(uri protocol.DocumentUri) {
+	sources := make([]string, 0, len(self.sources))
+	for source := range self.sources {
+		sources = append(sources, source)
+	}
+	slices.Sort(sources)
+
+	diagnostics := []protocol.Diagnostic{}
+	for _, source := range sources {
+		diagnostics = append(diagnostics, self.sources[source]...)
+	}
+
+	params := protocol.PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnostics,
+	}
+
+	if self.versionSupport && (self.diagnosticsVersion != nil) && (*self.diagnosticsVersion >= 0) {
+		version := protocol.UInteger(*self.diagnosticsVersion)
+		params.Version = &version
+	}
+
+	self.notify(string(protocol.ServerTextDocumentPublishDiagnostics), &params)
+}
+
+func supportsVersion(context *glsp.Context) bool {
+	if context.Session != nil {
+		if clientCapabilities, ok := context.Session.GetClientCapabilities().(interface{ SupportsPublishDiagnosticsVersion() bool }); ok {
+			return clientCapabilities.SupportsPublishDiagnosticsVersion()
+		}
+	}
+	return false
+}
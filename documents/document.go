@@ -0,0 +1,65 @@
+package documents
+
+import (
+	"fmt"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+//
+// Document
+//
+
+// An immutable snapshot of a text document at a specific version. Changes to
+// the document result in new snapshots, so it is safe to keep using a
+// snapshot while the document is being changed.
+type Document struct {
+	URI        protocol.DocumentUri
+	LanguageID string
+	Version    protocol.Integer
+	Content    string
+
+	lines *protocol.LineIndex
+}
+
+// Returns the line index for the content, which is maintained incrementally
+// by the [Store].
+func (self *Document) Lines() *protocol.LineIndex {
+	if (self.lines != nil) && (self.lines.Content() == self.Content) {
+		return self.lines
+	} else {
+		// Document was not created by the store or its content was modified
+		return protocol.NewLineIndex(self.Content)
+	}
+}
+
+func newDocument(item *protocol.TextDocumentItem) *Document {
+	return &Document{
+		URI:        item.URI,
+		LanguageID: item.LanguageID,
+		Version:    item.Version,
+		Content:    item.Text,
+		lines:      protocol.NewLineIndex(item.Text),
+	}
+}
+
+// Returns a new snapshot with the changes applied in order. The changes are
+// either [protocol.TextDocumentContentChangeEvent] or
+// [protocol.TextDocumentContentChangeEventWhole]. Ranges are interpreted
+// according to the position encoding.
+func (self *Document) Apply(version protocol.Integer, changes []any, encoding protocol.PositionEncodingKind) (*Document, error) {
+	document := *self
+	document.Version = version
+	document.lines = self.Lines()
+
+	for _, change := range changes {
+		if lines, err := document.lines.Apply(change, encoding); err == nil {
+			document.lines = lines
+		} else {
+			return nil, fmt.Errorf("%s for document: %s", err.Error(), self.URI)
+		}
+	}
+
+	document.Content = document.lines.Content()
+	return &document, nil
+}
@@ -0,0 +1,71 @@
+package documents
+
+import (
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Wraps the handler's text document synchronization funcs so that the store
+// is updated before they are called. The funcs may be nil, in which case the
+// store will be the only one handling the notifications. Must be called after
+// the funcs are set.
+//
+// For protocol_3_17 use the embedded handler, e.g. store.Register(&handler.Handler).
+func (self *Store) Register(handler *protocol.Handler) {
+	register(handler, func(context *glsp.Context) *Store {
+		return self
+	})
+}
+
+// Like [Store.Register] but keeps a separate store for every session. Use
+// [SessionStore] to access it.
+func RegisterSessionStores(handler *protocol.Handler) {
+	register(handler, SessionStore)
+}
+
+type sessionStoreKey struct{}
+
+var defaultStore = NewStore()
+
+// Returns the store for the context's session, creating it if necessary. If
+// the context has no session then a default store is shared.
+func SessionStore(context *glsp.Context) *Store {
+	if context.Session != nil {
+		return context.Session.GetOrCreate(sessionStoreKey{}, func() any {
+			return NewStore()
+		}).(*Store)
+	} else {
+		return defaultStore
+	}
+}
+
+func register(handler *protocol.Handler, getStore func(context *glsp.Context) *Store) {
+	didOpen := handler.TextDocumentDidOpen
+	handler.TextDocumentDidOpen = func(context *glsp.Context, params *protocol.DidOpenTextDocumentParams) error {
+		getStore(context).Open(&params.TextDocument)
+		if didOpen != nil {
+			return didOpen(context, params)
+		}
+		return nil
+	}
+
+	didChange := handler.TextDocumentDidChange
+	handler.TextDocumentDidChange = func(context *glsp.Context, params *protocol.DidChangeTextDocumentParams) error {
+		if _, err := getStore(context).Change(params, protocol.GetContextPositionEncoding(context)); err != nil {
+			return err
+		}
+		if didChange != nil {
+			return didChange(context, params)
+		}
+		return nil
+	}
+
+	didClose := handler.TextDocumentDidClose
+	handler.TextDocumentDidClose = func(context *glsp.Context, params *protocol.DidCloseTextDocumentParams) error {
+		getStore(context).Close(params.TextDocument.URI)
+		if didClose != nil {
+			return didClose(context, params)
+		}
+		return nil
+	}
+}
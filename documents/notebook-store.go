@@ -0,0 +1,318 @@
+package documents
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+	protocol317 "github.com/tliron/glsp/protocol_3_17"
+)
+
+//
+// NotebookStore
+//
+
+// A concurrency-safe store of open notebook documents. The text documents of
+// the cells are kept in a [Store], so that they can be accessed like any other
+// text document. See [NotebookStore.Register] for handling the notebook
+// document synchronization notifications automatically.
+type NotebookStore struct {
+	Documents *Store
+
+	notebooks map[protocol.URI]*Notebook
+	cells     map[protocol.DocumentUri]protocol.URI // cell document URI to notebook URI
+	lock      sync.RWMutex
+}
+
+// If documents is nil a new store will be created for the cells.
+func NewNotebookStore(documents *Store) *NotebookStore {
+	if documents == nil {
+		documents = NewStore()
+	}
+	return &NotebookStore{
+		Documents: documents,
+		notebooks: make(map[protocol.URI]*Notebook),
+		cells:     make(map[protocol.DocumentUri]protocol.URI),
+	}
+}
+
+// Returns the current snapshot of an open notebook.
+func (self *NotebookStore) Get(uri protocol.URI) (*Notebook, bool) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	notebook, ok := self.notebooks[uri]
+	return notebook, ok
+}
+
+// Returns the current snapshots of all open notebooks.
+func (self *NotebookStore) List() []*Notebook {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	notebooks := make([]*Notebook, 0, len(self.notebooks))
+	for _, notebook := range self.notebooks {
+		notebooks = append(notebooks, notebook)
+	}
+	return notebooks
+}
+
+// Returns the current snapshot of the notebook containing the cell text
+// document.
+func (self *NotebookStore) GetCellNotebook(uri protocol.DocumentUri) (*Notebook, bool) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	if notebookUri, ok := self.cells[uri]; ok {
+		notebook, ok := self.notebooks[notebookUri]
+		return notebook, ok
+	}
+	return nil, false
+}
+
+// Returns the current snapshots of the cell text documents in notebook order.
+// Cells with text documents that are not open are skipped.
+func (self *NotebookStore) CellDocuments(uri protocol.URI) []*Document {
+	notebook, ok := self.Get(uri)
+	if !ok {
+		return nil
+	}
+
+	documents := make([]*Document, 0, len(notebook.Cells))
+	for _, cell := range notebook.Cells {
+		if document, ok := self.Documents.Get(cell.Document); ok {
+			documents = append(documents, document)
+		}
+	}
+	return documents
+}
+
+// Opening an already open notebook replaces it.
+func (self *NotebookStore) Open(params *protocol317.DidOpenNotebookDocumentParams) *Notebook {
+	notebook := &Notebook{
+		URI:          params.NotebookDocument.URI,
+		NotebookType: params.NotebookDocument.NotebookType,
+		Version:      params.NotebookDocument.Version,
+		Metadata:     params.NotebookDocument.Metadata,
+		Cells:        slices.Clone(params.NotebookDocument.Cells),
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if previous, ok := self.notebooks[notebook.URI]; ok {
+		self.removeCells(previous.Cells)
+	}
+	self.notebooks[notebook.URI] = notebook
+	self.addCells(notebook.URI, notebook.Cells)
+
+	for index := range params.CellTextDocuments {
+		self.Documents.Open(&params.CellTextDocuments[index])
+	}
+
+	return notebook
+}
+
+// The new version must be greater than the current version, as must the new
+// versions of changed cell text documents. Ranges in cell text changes are
+// interpreted according to the position encoding. If an error is returned
+// then neither the notebook nor its cell text documents were changed.
+func (self *NotebookStore) Change(params *protocol317.DidChangeNotebookDocumentParams, encoding protocol.PositionEncodingKind) (*Notebook, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	uri := params.NotebookDocument.URI
+	current, ok := self.notebooks[uri]
+	if !ok {
+		return nil, fmt.Errorf("notebook not open: %s", uri)
+	}
+
+	if params.NotebookDocument.Version <= current.Version {
+		return nil, fmt.Errorf("notebook version %d is not newer than %d: %s", params.NotebookDocument.Version, current.Version, uri)
+	}
+
+	notebook := *current
+	notebook.Version = params.NotebookDocument.Version
+	notebook.Cells = slices.Clone(current.Cells)
+
+	if params.Change.Metadata != nil {
+		notebook.Metadata = params.Change.Metadata
+	}
+
+	cells := params.Change.Cells
+	if cells == nil {
+		self.notebooks[uri] = &notebook
+		return &notebook, nil
+	}
+
+	structure := cells.Structure
+	if structure != nil {
+		start := int(structure.Array.Start)
+		end := start + int(structure.Array.DeleteCount)
+		if end > len(notebook.Cells) {
+			return nil, fmt.Errorf("cells %d to %d are out of bounds for %d cells: %s", start, end, len(notebook.Cells), uri)
+		}
+		notebook.Cells = slices.Replace(notebook.Cells, start, end, structure.Array.Cells...)
+	}
+
+	for _, cell := range cells.Data {
+		if index := notebook.CellIndex(cell.Document); index != -1 {
+			notebook.Cells[index] = cell
+		} else {
+			return nil, fmt.Errorf("notebook has no cell %s: %s", cell.Document, uri)
+		}
+	}
+
+	// Apply the text changes to new snapshots, so that nothing is updated if
+	// one of them fails
+	changed := make(map[protocol.DocumentUri]*Document)
+	for _, textContent := range cells.TextContent {
+		uri_ := textContent.Document.URI
+		document, ok := changed[uri_]
+		if !ok {
+			if document, ok = self.getCellDocument(structure, uri_); !ok {
+				return nil, fmt.Errorf("cell document not open: %s", uri_)
+			}
+		}
+
+		if textContent.Document.Version <= document.Version {
+			return nil, fmt.Errorf("cell document version %d is not newer than %d: %s", textContent.Document.Version, document.Version, uri_)
+		}
+
+		var err error
+		if changed[uri_], err = document.Apply(textContent.Document.Version, textContent.Changes, encoding); err != nil {
+			return nil, err
+		}
+	}
+
+	// The changes are valid, so we can now update the stores
+
+	if structure != nil {
+		self.removeCells(current.Cells)
+		self.addCells(uri, notebook.Cells)
+		for index := range structure.DidOpen {
+			self.Documents.Open(&structure.DidOpen[index])
+		}
+		for _, identifier := range structure.DidClose {
+			self.Documents.Close(identifier.URI)
+		}
+	}
+
+	self.Documents.put(changed)
+	self.notebooks[uri] = &notebook
+
+	return &notebook, nil
+}
+
+func (self *NotebookStore) Close(params *protocol317.DidCloseNotebookDocumentParams) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if notebook, ok := self.notebooks[params.NotebookDocument.URI]; ok {
+		self.removeCells(notebook.Cells)
+		delete(self.notebooks, params.NotebookDocument.URI)
+	}
+
+	for _, identifier := range params.CellTextDocuments {
+		self.Documents.Close(identifier.URI)
+	}
+}
+
+// Wraps the handler's notebook document synchronization funcs so that the
+// store is updated before they are called. The funcs may be nil, in which
+// case the store will be the only one handling the notifications. Must be
+// called after the funcs are set.
+func (self *NotebookStore) Register(handler *protocol317.Handler) {
+	registerNotebooks(handler, func(context *glsp.Context) *NotebookStore {
+		return self
+	})
+}
+
+// Like [NotebookStore.Register] but keeps a separate store for every session.
+// Use [SessionNotebookStore] to access it.
+func RegisterSessionNotebookStores(handler *protocol317.Handler) {
+	registerNotebooks(handler, SessionNotebookStore)
+}
+
+type sessionNotebookStoreKey struct{}
+
+var defaultNotebookStore = NewNotebookStore(defaultStore)
+
+// Returns the notebook store for the context's session, creating it if
+// necessary. Its cell text documents are kept in the session's [SessionStore].
+// If the context has no session then a default store is shared.
+func SessionNotebookStore(context *glsp.Context) *NotebookStore {
+	if context.Session != nil {
+		// Note that we cannot call SessionStore while the session is locked
+		documents := SessionStore(context)
+		return context.Session.GetOrCreate(sessionNotebookStoreKey{}, func() any {
+			return NewNotebookStore(documents)
+		}).(*NotebookStore)
+	} else {
+		return defaultNotebookStore
+	}
+}
+
+// Returns the cell text document as it will be after the structure change,
+// which can be nil.
+func (self *NotebookStore) getCellDocument(structure *protocol317.NotebookDocumentChangeEventCellsStructure, uri protocol.DocumentUri) (*Document, bool) {
+	if structure != nil {
+		for _, identifier := range structure.DidClose {
+			if identifier.URI == uri {
+				return nil, false
+			}
+		}
+
+		for index := range structure.DidOpen {
+			if structure.DidOpen[index].URI == uri {
+				return newDocument(&structure.DidOpen[index]), true
+			}
+		}
+	}
+
+	return self.Documents.Get(uri)
+}
+
+// Must be called while holding the lock
+func (self *NotebookStore) addCells(uri protocol.URI, cells []protocol317.NotebookCell) {
+	for _, cell := range cells {
+		self.cells[cell.Document] = uri
+	}
+}
+
+// Must be called while holding the lock
+func (self *NotebookStore) removeCells(cells []protocol317.NotebookCell) {
+	for _, cell := range cells {
+		delete(self.cells, cell.Document)
+	}
+}
+
+func registerNotebooks(handler *protocol317.Handler, getStore func(context *glsp.Context) *NotebookStore) {
+	didOpen := handler.NotebookDocumentDidOpen
+	handler.NotebookDocumentDidOpen = func(context *glsp.Context, params *protocol317.DidOpenNotebookDocumentParams) error {
+		getStore(context).Open(params)
+		if didOpen != nil {
+			return didOpen(context, params)
+		}
+		return nil
+	}
+
+	didChange := handler.NotebookDocumentDidChange
+	handler.NotebookDocumentDidChange = func(context *glsp.Context, params *protocol317.DidChangeNotebookDocumentParams) error {
+		if _, err := getStore(context).Change(params, protocol.GetContextPositionEncoding(context)); err != nil {
+			return err
+		}
+		if didChange != nil {
+			return didChange(context, params)
+		}
+		return nil
+	}
+
+	didClose := handler.NotebookDocumentDidClose
+	handler.NotebookDocumentDidClose = func(context *glsp.Context, params *protocol317.DidCloseNotebookDocumentParams) error {
+		getStore(context).Close(params)
+		if didClose != nil {
+			return didClose(context, params)
+		}
+		return nil
+	}
+}
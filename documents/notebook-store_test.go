@@ -0,0 +1,281 @@
+package documents
+
+import (
+	"maps"
+	"reflect"
+	"strings"
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+	protocol317 "github.com/tliron/glsp/protocol_3_17"
+)
+
+const testNotebookURI protocol.URI = "file:///notebook.ipynb"
+
+func TestNotebookStore(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes []*protocol317.DidChangeNotebookDocumentParams
+		close   []protocol.DocumentUri // cell text documents to close, nil to keep the notebook open
+
+		expectError     bool // from the last change
+		allowErrors     bool // from changes before the last one
+		expectVersion   protocol.Integer
+		expectCells     []protocol317.NotebookCell // nil if the notebook is expected to be closed
+		expectUnmapped  []protocol.DocumentUri     // cells that must not map to the notebook
+		expectDocuments map[protocol.DocumentUri]string
+	}{
+		{
+			name:            "open",
+			expectVersion:   1,
+			expectCells:     []protocol317.NotebookCell{testCell("a"), testCell("b"), testCell("c")},
+			expectDocuments: map[protocol.DocumentUri]string{"a": "A", "b": "B", "c": "C"},
+		},
+		{
+			name: "insert cell",
+			changes: []*protocol317.DidChangeNotebookDocumentParams{
+				testStructureChange(2, 1, 0, []protocol.DocumentUri{"d"}, nil),
+			},
+			expectVersion:   2,
+			expectCells:     []protocol317.NotebookCell{testCell("a"), testCell("d"), testCell("b"), testCell("c")},
+			expectDocuments: map[protocol.DocumentUri]string{"a": "A", "b": "B", "c": "C", "d": "D"},
+		},
+		{
+			name: "splice out cells",
+			changes: []*protocol317.DidChangeNotebookDocumentParams{
+				testStructureChange(2, 0, 2, []protocol.DocumentUri{"d"}, []protocol.DocumentUri{"a", "b"}),
+			},
+			expectVersion:   2,
+			expectCells:     []protocol317.NotebookCell{testCell("d"), testCell("c")},
+			expectUnmapped:  []protocol.DocumentUri{"a", "b"},
+			expectDocuments: map[protocol.DocumentUri]string{"c": "C", "d": "D"},
+		},
+		{
+			name: "splice out cells twice",
+			changes: []*protocol317.DidChangeNotebookDocumentParams{
+				testStructureChange(2, 2, 1, nil, []protocol.DocumentUri{"c"}),
+				testStructureChange(3, 0, 1, nil, []protocol.DocumentUri{"a"}),
+			},
+			expectVersion:   3,
+			expectCells:     []protocol317.NotebookCell{testCell("b")},
+			expectUnmapped:  []protocol.DocumentUri{"a", "c"},
+			expectDocuments: map[protocol.DocumentUri]string{"b": "B"},
+		},
+		{
+			name: "cell data",
+			changes: []*protocol317.DidChangeNotebookDocumentParams{
+				testChange(2, &protocol317.NotebookDocumentChangeEventCells{
+					Data: []protocol317.NotebookCell{{Kind: protocol317.NotebookCellKindMarkup, Document: "b"}},
+				}),
+			},
+			expectVersion:   2,
+			expectCells:     []protocol317.NotebookCell{testCell("a"), {Kind: protocol317.NotebookCellKindMarkup, Document: "b"}, testCell("c")},
+			expectDocuments: map[protocol.DocumentUri]string{"a": "A", "b": "B", "c": "C"},
+		},
+		{
+			name: "cell text content",
+			changes: []*protocol317.DidChangeNotebookDocumentParams{
+				testTextChange(testChange(2, &protocol317.NotebookDocumentChangeEventCells{}), "b", 2, "B2"),
+			},
+			expectVersion:   2,
+			expectCells:     []protocol317.NotebookCell{testCell("a"), testCell("b"), testCell("c")},
+			expectDocuments: map[protocol.DocumentUri]string{"a": "A", "b": "B2", "c": "C"},
+		},
+		{
+			name: "new cell text content",
+			changes: []*protocol317.DidChangeNotebookDocumentParams{
+				testTextChange(testStructureChange(2, 3, 0, []protocol.DocumentUri{"d"}, nil), "d", 2, "D2"),
+			},
+			expectVersion:   2,
+			expectCells:     []protocol317.NotebookCell{testCell("a"), testCell("b"), testCell("c"), testCell("d")},
+			expectDocuments: map[protocol.DocumentUri]string{"a": "A", "b": "B", "c": "C", "d": "D2"},
+		},
+		{
+			name: "stale cell text version",
+			changes: []*protocol317.DidChangeNotebookDocumentParams{
+				testTextChange(testTextChange(testStructureChange(2, 0, 1, []protocol.DocumentUri{"d"}, []protocol.DocumentUri{"a"}), "c", 2, "C2"), "b", 1, "B2"),
+			},
+			expectError:     true,
+			expectVersion:   1,
+			expectCells:     []protocol317.NotebookCell{testCell("a"), testCell("b"), testCell("c")},
+			expectDocuments: map[protocol.DocumentUri]string{"a": "A", "b": "B", "c": "C"},
+		},
+		{
+			name: "closed cell text content",
+			changes: []*protocol317.DidChangeNotebookDocumentParams{
+				testTextChange(testStructureChange(2, 0, 1, nil, []protocol.DocumentUri{"a"}), "a", 2, "A2"),
+			},
+			expectError:     true,
+			expectVersion:   1,
+			expectCells:     []protocol317.NotebookCell{testCell("a"), testCell("b"), testCell("c")},
+			expectDocuments: map[protocol.DocumentUri]string{"a": "A", "b": "B", "c": "C"},
+		},
+		{
+			name: "retry after stale cell text version",
+			changes: []*protocol317.DidChangeNotebookDocumentParams{
+				testTextChange(testChange(2, &protocol317.NotebookDocumentChangeEventCells{}), "b", 1, "B2"),
+				testTextChange(testChange(2, &protocol317.NotebookDocumentChangeEventCells{}), "b", 2, "B2"),
+			},
+			allowErrors:     true,
+			expectVersion:   2,
+			expectCells:     []protocol317.NotebookCell{testCell("a"), testCell("b"), testCell("c")},
+			expectDocuments: map[protocol.DocumentUri]string{"a": "A", "b": "B2", "c": "C"},
+		},
+		{
+			name: "cells out of bounds",
+			changes: []*protocol317.DidChangeNotebookDocumentParams{
+				testStructureChange(2, 2, 5, nil, []protocol.DocumentUri{"c"}),
+			},
+			expectError:     true,
+			expectVersion:   1,
+			expectCells:     []protocol317.NotebookCell{testCell("a"), testCell("b"), testCell("c")},
+			expectDocuments: map[protocol.DocumentUri]string{"a": "A", "b": "B", "c": "C"},
+		},
+		{
+			name: "unknown cell data",
+			changes: []*protocol317.DidChangeNotebookDocumentParams{
+				testChange(2, &protocol317.NotebookDocumentChangeEventCells{
+					Data: []protocol317.NotebookCell{testCell("x")},
+				}),
+			},
+			expectError:     true,
+			expectVersion:   1,
+			expectCells:     []protocol317.NotebookCell{testCell("a"), testCell("b"), testCell("c")},
+			expectDocuments: map[protocol.DocumentUri]string{"a": "A", "b": "B", "c": "C"},
+		},
+		{
+			name: "stale version",
+			changes: []*protocol317.DidChangeNotebookDocumentParams{
+				testStructureChange(1, 0, 1, nil, []protocol.DocumentUri{"a"}),
+			},
+			expectError:     true,
+			expectVersion:   1,
+			expectCells:     []protocol317.NotebookCell{testCell("a"), testCell("b"), testCell("c")},
+			expectDocuments: map[protocol.DocumentUri]string{"a": "A", "b": "B", "c": "C"},
+		},
+		{
+			name:            "close",
+			close:           []protocol.DocumentUri{"a", "b", "c"},
+			expectUnmapped:  []protocol.DocumentUri{"a", "b", "c"},
+			expectDocuments: map[protocol.DocumentUri]string{},
+		},
+		{
+			name: "close after splice",
+			changes: []*protocol317.DidChangeNotebookDocumentParams{
+				testStructureChange(2, 0, 1, []protocol.DocumentUri{"d"}, []protocol.DocumentUri{"a"}),
+			},
+			close:           []protocol.DocumentUri{"d", "b", "c"},
+			expectUnmapped:  []protocol.DocumentUri{"a", "b", "c", "d"},
+			expectDocuments: map[protocol.DocumentUri]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := NewNotebookStore(nil)
+			store.Open(&protocol317.DidOpenNotebookDocumentParams{
+				NotebookDocument: protocol317.NotebookDocument{
+					URI:          testNotebookURI,
+					NotebookType: "jupyter-notebook",
+					Version:      1,
+					Cells:        []protocol317.NotebookCell{testCell("a"), testCell("b"), testCell("c")},
+				},
+				CellTextDocuments: []protocol.TextDocumentItem{testItem("a"), testItem("b"), testItem("c")},
+			})
+
+			for index, change := range test.changes {
+				last := index == len(test.changes)-1
+				if _, err := store.Change(change, protocol.PositionEncodingKindUTF16); err != nil {
+					if !(last && test.expectError) && !(!last && test.allowErrors) {
+						t.Fatal(err)
+					}
+				} else if last && test.expectError {
+					t.Fatal("expected an error")
+				}
+			}
+
+			if test.close != nil {
+				closeParams := protocol317.DidCloseNotebookDocumentParams{NotebookDocument: protocol317.NotebookDocumentIdentifier{URI: testNotebookURI}}
+				for _, uri := range test.close {
+					closeParams.CellTextDocuments = append(closeParams.CellTextDocuments, protocol.TextDocumentIdentifier{URI: uri})
+				}
+				store.Close(&closeParams)
+			}
+
+			notebook, ok := store.Get(testNotebookURI)
+			if test.expectCells == nil {
+				if ok {
+					t.Errorf("expected notebook to be closed, got version %d", notebook.Version)
+				}
+			} else if !ok {
+				t.Fatal("expected notebook to be open")
+			} else {
+				if notebook.Version != test.expectVersion {
+					t.Errorf("expected version %d, got %d", test.expectVersion, notebook.Version)
+				}
+				if !reflect.DeepEqual(notebook.Cells, test.expectCells) {
+					t.Errorf("expected cells %v, got %v", test.expectCells, notebook.Cells)
+				}
+				for _, cell := range notebook.Cells {
+					if cellNotebook, ok := store.GetCellNotebook(cell.Document); !ok || (cellNotebook != notebook) {
+						t.Errorf("expected cell %s to map to the notebook", cell.Document)
+					}
+				}
+			}
+
+			for _, uri := range test.expectUnmapped {
+				if _, ok := store.GetCellNotebook(uri); ok {
+					t.Errorf("expected cell %s not to map to the notebook", uri)
+				}
+			}
+
+			documents := make(map[protocol.DocumentUri]string)
+			for _, document := range store.Documents.List() {
+				documents[document.URI] = document.Content
+			}
+			if !maps.Equal(documents, test.expectDocuments) {
+				t.Errorf("expected documents %v, got %v", test.expectDocuments, documents)
+			}
+		})
+	}
+}
+
+func testCell(uri protocol.DocumentUri) protocol317.NotebookCell {
+	return protocol317.NotebookCell{Kind: protocol317.NotebookCellKindCode, Document: uri}
+}
+
+// The content is the upper-case URI
+func testItem(uri protocol.DocumentUri) protocol.TextDocumentItem {
+	return protocol.TextDocumentItem{URI: uri, LanguageID: "python", Version: 1, Text: strings.ToUpper(uri)}
+}
+
+func testChange(version protocol.Integer, cells *protocol317.NotebookDocumentChangeEventCells) *protocol317.DidChangeNotebookDocumentParams {
+	return &protocol317.DidChangeNotebookDocumentParams{
+		NotebookDocument: protocol317.VersionedNotebookDocumentIdentifier{URI: testNotebookURI, Version: version},
+		Change:           protocol317.NotebookDocumentChangeEvent{Cells: cells},
+	}
+}
+
+// Adds a change that replaces the whole text of the cell
+func testTextChange(change *protocol317.DidChangeNotebookDocumentParams, uri protocol.DocumentUri, version protocol.Integer, text string) *protocol317.DidChangeNotebookDocumentParams {
+	change.Change.Cells.TextContent = append(change.Change.Cells.TextContent, protocol317.NotebookDocumentChangeEventCellsTextContent{
+		Document: protocol.VersionedTextDocumentIdentifier{TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri}, Version: version},
+		Changes:  []any{protocol.TextDocumentContentChangeEventWhole{Text: text}},
+	})
+	return change
+}
+
+// Replaces deleteCount cells at start with newly opened cells
+func testStructureChange(version protocol.Integer, start protocol.UInteger, deleteCount protocol.UInteger, open []protocol.DocumentUri, close []protocol.DocumentUri) *protocol317.DidChangeNotebookDocumentParams {
+	structure := protocol317.NotebookDocumentChangeEventCellsStructure{
+		Array: protocol317.NotebookCellArrayChange{Start: start, DeleteCount: deleteCount},
+	}
+	for _, uri := range open {
+		structure.Array.Cells = append(structure.Array.Cells, testCell(uri))
+		structure.DidOpen = append(structure.DidOpen, testItem(uri))
+	}
+	for _, uri := range close {
+		structure.DidClose = append(structure.DidClose, protocol.TextDocumentIdentifier{URI: uri})
+	}
+	return testChange(version, &protocol317.NotebookDocumentChangeEventCells{Structure: &structure})
+}
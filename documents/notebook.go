@@ -0,0 +1,32 @@
+package documents
+
+import (
+	protocol "github.com/tliron/glsp/protocol_3_16"
+	protocol317 "github.com/tliron/glsp/protocol_3_17"
+)
+
+//
+// Notebook
+//
+
+// An immutable snapshot of a notebook document at a specific version. The
+// content of the cells is kept as text documents, see
+// [NotebookStore.CellDocuments].
+type Notebook struct {
+	URI          protocol.URI
+	NotebookType string
+	Version      protocol.Integer
+	Metadata     map[string]any
+	Cells        []protocol317.NotebookCell // in notebook order
+}
+
+// Returns the index of the cell with the text document, or -1 if the
+// notebook has no such cell.
+func (self *Notebook) CellIndex(uri protocol.DocumentUri) int {
+	for index, cell := range self.Cells {
+		if cell.Document == uri {
+			return index
+		}
+	}
+	return -1
+}
@@ -0,0 +1,93 @@
+package documents
+
+import (
+	"fmt"
+	"sync"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+//
+// Store
+//
+
+// A concurrency-safe store of open text documents. See [Store.Register] for
+// handling the text document synchronization notifications automatically.
+type Store struct {
+	documents map[protocol.DocumentUri]*Document
+	lock      sync.RWMutex
+}
+
+func NewStore() *Store {
+	return &Store{
+		documents: make(map[protocol.DocumentUri]*Document),
+	}
+}
+
+// Returns the current snapshot of an open document.
+func (self *Store) Get(uri protocol.DocumentUri) (*Document, bool) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	document, ok := self.documents[uri]
+	return document, ok
+}
+
+// Returns the current snapshots of all open documents.
+func (self *Store) List() []*Document {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	documents := make([]*Document, 0, len(self.documents))
+	for _, document := range self.documents {
+		documents = append(documents, document)
+	}
+	return documents
+}
+
+// Opening an already open document replaces it.
+func (self *Store) Open(item *protocol.TextDocumentItem) *Document {
+	document := newDocument(item)
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.documents[item.URI] = document
+	return document
+}
+
+// The new version must be greater than the current version. Ranges are
+// interpreted according to the position encoding.
+func (self *Store) Change(params *protocol.DidChangeTextDocumentParams, encoding protocol.PositionEncodingKind) (*Document, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	uri := params.TextDocument.URI
+	if document, ok := self.documents[uri]; ok {
+		if params.TextDocument.Version <= document.Version {
+			return nil, fmt.Errorf("document version %d is not newer than %d: %s", params.TextDocument.Version, document.Version, uri)
+		}
+
+		if document, err := document.Apply(params.TextDocument.Version, params.ContentChanges, encoding); err == nil {
+			self.documents[uri] = document
+			return document, nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, fmt.Errorf("document not open: %s", uri)
+	}
+}
+
+func (self *Store) Close(uri protocol.DocumentUri) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	delete(self.documents, uri)
+}
+
+// Replaces the snapshots of the documents, e.g. after applying changes to them
+// with [Document.Apply].
+func (self *Store) put(documents map[protocol.DocumentUri]*Document) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for uri, document := range documents {
+		self.documents[uri] = document
+	}
+}
@@ -0,0 +1,49 @@
+package glsp
+
+import (
+	"fmt"
+)
+
+// JSON-RPC and LSP error codes. See:
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/#errorCodes
+const (
+	CodeParseError     int64 = -32700
+	CodeInvalidRequest int64 = -32600
+	CodeMethodNotFound int64 = -32601
+	CodeInvalidParams  int64 = -32602
+	CodeInternalError  int64 = -32603
+
+	CodeServerNotInitialized int64 = -32002
+	CodeUnknownErrorCode     int64 = -32001
+
+	CodeRequestFailed    int64 = -32803 // since 3.17
+	CodeServerCancelled  int64 = -32802 // since 3.17
+	CodeContentModified  int64 = -32801
+	CodeRequestCancelled int64 = -32800
+)
+
+//
+// Error
+//
+
+// A JSON-RPC error, such as the one returned by the client when a
+// server-to-client call fails.
+//
+// Handlers can return it (or an error wrapping it) in order to control the
+// error sent to the client, e.g. to return [CodeContentModified] or to
+// include data such as DiagnosticServerCancellationData. Other errors are sent
+// with [CodeRequestFailed].
+type Error struct {
+	Code    int64  `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"` // json.RawMessage when received from the client
+}
+
+// ([error] interface)
+func (self *Error) Error() string {
+	if self.Message != "" {
+		return fmt.Sprintf("code %d: %s", self.Code, self.Message)
+	} else {
+		return fmt.Sprintf("code %d", self.Code)
+	}
+}
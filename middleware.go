@@ -0,0 +1,32 @@
+package glsp
+
+//
+// HandlerFunc
+//
+
+// Allows an ordinary function to be used as a [Handler].
+type HandlerFunc func(context *Context) (result any, validMethod bool, validParams bool, err error)
+
+// ([Handler] interface)
+func (self HandlerFunc) Handle(context *Context) (result any, validMethod bool, validParams bool, err error) {
+	return self(context)
+}
+
+//
+// Middleware
+//
+
+// Wraps a handler in order to add behavior before and/or after it handles
+// messages. A middleware may also handle a message itself without calling the
+// next handler.
+type Middleware func(next Handler) Handler
+
+// Wraps the handler with the middlewares. The first middleware is the
+// outermost, so it is the first to see a message and the last to see its
+// result.
+func Chain(handler Handler, middlewares ...Middleware) Handler {
+	for index := len(middlewares) - 1; index >= 0; index-- {
+		handler = middlewares[index](handler)
+	}
+	return handler
+}
@@ -0,0 +1,65 @@
+package partial
+
+import (
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+type HandlerFunc[P any, T any] func(context *glsp.Context, params *P, sink *Sink[T]) error
+
+// Adapts a streaming handler to a regular handler func for any request that
+// returns a list. The token func should return the params' partial result
+// token, which may be nil.
+func Adapt[P any, T any](handle HandlerFunc[P, T], token func(params *P) *protocol.ProgressToken) func(context *glsp.Context, params *P) ([]T, error) {
+	return func(context *glsp.Context, params *P) ([]T, error) {
+		sink := NewSink[T](context, token(params))
+		if err := handle(context, params, sink); err != nil {
+			return nil, err
+		}
+		return sink.Result(), nil
+	}
+}
+
+// For [protocol.Handler].TextDocumentReferences.
+func References(handle HandlerFunc[protocol.ReferenceParams, protocol.Location]) protocol.TextDocumentReferencesFunc {
+	return Adapt(handle, func(params *protocol.ReferenceParams) *protocol.ProgressToken {
+		return params.PartialResultToken
+	})
+}
+
+// For [protocol.Handler].WorkspaceSymbol.
+func WorkspaceSymbols(handle HandlerFunc[protocol.WorkspaceSymbolParams, protocol.SymbolInformation]) protocol.WorkspaceSymbolFunc {
+	return Adapt(handle, func(params *protocol.WorkspaceSymbolParams) *protocol.ProgressToken {
+		return params.PartialResultToken
+	})
+}
+
+// For [protocol.Handler].TextDocumentDocumentSymbol.
+func DocumentSymbols(handle HandlerFunc[protocol.DocumentSymbolParams, protocol.DocumentSymbol]) protocol.TextDocumentDocumentSymbolFunc {
+	adapted := Adapt(handle, func(params *protocol.DocumentSymbolParams) *protocol.ProgressToken {
+		return params.PartialResultToken
+	})
+	return func(context *glsp.Context, params *protocol.DocumentSymbolParams) (any, error) {
+		if result, err := adapted(context, params); err == nil {
+			return result, nil
+		} else {
+			return nil, err
+		}
+	}
+}
+
+// For [protocol.Handler].TextDocumentSemanticTokensFull. Every batch must
+// continue the relative encoding of the previous one, e.g. by using a single
+// [semantictokens.Builder] and pushing slices of its encoded data.
+func SemanticTokensFull(handle HandlerFunc[protocol.SemanticTokensParams, protocol.UInteger]) protocol.TextDocumentSemanticTokensFullFunc {
+	return func(context *glsp.Context, params *protocol.SemanticTokensParams) (*protocol.SemanticTokens, error) {
+		sink := NewSink[protocol.UInteger](context, params.PartialResultToken)
+		sink.Wrap = func(batch []protocol.UInteger) any {
+			return &protocol.SemanticTokensPartialResult{Data: batch}
+		}
+		if err := handle(context, params, sink); err != nil {
+			return nil, err
+		}
+		return &protocol.SemanticTokens{Data: sink.Result()}, nil
+	}
+}
@@ -0,0 +1,88 @@
+package partial
+
+import (
+	"sync"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+//
+// Sink
+//
+
+// Receives partial results for a request. If the client provided a partial
+// result token then every batch is sent immediately as a "$/progress"
+// notification. Otherwise batches are buffered and returned together in the
+// final response.
+type Sink[T any] struct {
+	// Converts a batch to the value of the "$/progress" notification. When
+	// nil the batch itself is sent, as a JSON array.
+	Wrap func(batch []T) any
+
+	context *glsp.Context
+	token   *protocol.ProgressToken // nil if buffering
+	buffer  []T
+	lock    sync.Mutex
+}
+
+// The partial result token may be nil, in which case the sink will buffer.
+func NewSink[T any](context *glsp.Context, partialResultToken *protocol.ProgressToken) *Sink[T] {
+	return &Sink[T]{
+		context: context,
+		token:   partialResultToken,
+	}
+}
+
+// Returns true if batches are sent to the client rather than buffered.
+func (self *Sink[T]) IsStreaming() bool {
+	return self.token != nil
+}
+
+// Empty batches are ignored. Returns an error if the request's context is done,
+// which can be used to stop producing results.
+func (self *Sink[T]) Push(batch ...T) error {
+	if self.context.Context != nil {
+		if err := self.context.Context.Err(); err != nil {
+			return err
+		}
+	}
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.token == nil {
+		self.buffer = append(self.buffer, batch...)
+		return nil
+	}
+
+	var value any = batch
+	if self.Wrap != nil {
+		value = self.Wrap(batch)
+	}
+
+	self.context.Notify(string(protocol.MethodProgress), &protocol.ProgressParams{
+		Token: *self.token,
+		Value: value,
+	})
+	return nil
+}
+
+// The value to use for the final response. When streaming it is always an
+// empty (but not nil) slice, because per the specification all results must
+// have been reported via "$/progress". Otherwise it is all the buffered
+// batches.
+func (self *Sink[T]) Result() []T {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.buffer == nil {
+		return []T{}
+	} else {
+		return self.buffer
+	}
+}
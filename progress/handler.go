@@ -0,0 +1,77 @@
+package progress
+
+import (
+	contextpkg "context"
+	"sync"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Wraps the handler's WindowWorkDoneProgressCancel func so that the
+// Reporter.Context for the token is cancelled before it is called. The func
+// may be nil. Must be called after the func is set.
+//
+// For protocol_3_17 use the embedded handler, e.g. progress.Register(&handler.Handler).
+func Register(handler *protocol.Handler) {
+	workDoneProgressCancel := handler.WindowWorkDoneProgressCancel
+	handler.WindowWorkDoneProgressCancel = func(context *glsp.Context, params *protocol.WorkDoneProgressCancelParams) error {
+		contextRegistry(context).cancel(&params.Token)
+		if workDoneProgressCancel != nil {
+			return workDoneProgressCancel(context, params)
+		}
+		return nil
+	}
+}
+
+//
+// registry
+//
+
+type registry struct {
+	cancels map[any]contextpkg.CancelFunc // key is token value
+	lock    sync.Mutex
+}
+
+type registryKey struct{}
+
+var defaultRegistry = newRegistry()
+
+func newRegistry() *registry {
+	return &registry{cancels: make(map[any]contextpkg.CancelFunc)}
+}
+
+// Tokens are unique per session. If the context has no session then a default
+// registry is shared.
+func contextRegistry(context *glsp.Context) *registry {
+	if context.Session != nil {
+		return context.Session.GetOrCreate(registryKey{}, func() any {
+			return newRegistry()
+		}).(*registry)
+	} else {
+		return defaultRegistry
+	}
+}
+
+func (self *registry) add(token *protocol.ProgressToken, cancel contextpkg.CancelFunc) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.cancels[token.Value] = cancel
+}
+
+func (self *registry) remove(token *protocol.ProgressToken) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	delete(self.cancels, token.Value)
+}
+
+func (self *registry) cancel(token *protocol.ProgressToken) {
+	self.lock.Lock()
+	cancel, ok := self.cancels[token.Value]
+	delete(self.cancels, token.Value)
+	self.lock.Unlock()
+
+	if ok {
+		cancel()
+	}
+}
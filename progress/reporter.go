@@ -0,0 +1,174 @@
+package progress
+
+import (
+	contextpkg "context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Used when Reporter.MinReportInterval is 0
+var DefaultMinReportInterval = 100 * time.Millisecond
+
+var lastTokenID atomic.Uint64
+
+//
+// Reporter
+//
+
+// Reports work done progress for a single operation. It does nothing if the
+// client does not support progress, so it is always safe to use.
+type Reporter struct {
+	// Cancelled when the client cancels the progress (see [Register]) or
+	// when the progress ends.
+	Context contextpkg.Context
+
+	// Reports sent sooner than this after the previous report are dropped.
+	MinReportInterval time.Duration
+
+	glspContext *glsp.Context
+	token       *protocol.ProgressToken // nil if not reporting
+	cancel      contextpkg.CancelFunc
+	begun       bool
+	ended       bool
+	lastReport  time.Time
+	lock        sync.Mutex
+}
+
+// Uses the token provided by the client, e.g. in
+// [protocol.WorkDoneProgressParams], if not nil. Otherwise creates a new token
+// by calling the client, but only if it supports it. Reporter.Context is
+// derived from the glsp.Context's context.
+func New(context *glsp.Context, workDoneToken *protocol.ProgressToken) (*Reporter, error) {
+	token := workDoneToken
+	if token == nil {
+		if context.Session != nil {
+			if clientCapabilities, ok := context.Session.GetClientCapabilities().(interface{ SupportsWorkDoneProgress() bool }); ok && clientCapabilities.SupportsWorkDoneProgress() {
+				token = &protocol.ProgressToken{Value: fmt.Sprintf("glsp-progress-%d", lastTokenID.Add(1))}
+				if err := protocol.WorkDoneProgressCreate(context, &protocol.WorkDoneProgressCreateParams{Token: *token}); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	parent := context.Context
+	if parent == nil {
+		parent = contextpkg.Background()
+	}
+
+	self := Reporter{
+		MinReportInterval: DefaultMinReportInterval,
+		glspContext:       context,
+		token:             token,
+	}
+	self.Context, self.cancel = contextpkg.WithCancel(parent)
+
+	if token != nil {
+		contextRegistry(context).add(token, self.cancel)
+	}
+
+	return &self, nil
+}
+
+// Returns nil if progress is not reported.
+func (self *Reporter) Token() *protocol.ProgressToken {
+	return self.token
+}
+
+// Must be called once before [Reporter.Report] and [Reporter.End]. If
+// cancellable, the client may show a cancel button, which will cancel
+// Reporter.Context. The progress begins at 0%, because clients may otherwise
+// assume that it is infinite and ignore the percentages that are reported.
+func (self *Reporter) Begin(title string, cancellable bool) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.begun {
+		return fmt.Errorf("progress already begun: %s", title)
+	}
+	self.begun = true
+	self.lastReport = time.Now()
+
+	var percentage protocol.UInteger
+	return self.notify(&protocol.WorkDoneProgressBegin{
+		Kind:        "begin",
+		Title:       title,
+		Cancellable: &cancellable,
+		Percentage:  &percentage,
+	})
+}
+
+// The percentage (from 0 to 100) should not decrease. The message can be
+// empty. Reports that are too frequent are dropped, see
+// Reporter.MinReportInterval.
+func (self *Reporter) Report(percentage protocol.UInteger, message string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if !self.begun || self.ended {
+		return nil
+	}
+
+	interval := self.MinReportInterval
+	if interval <= 0 {
+		interval = DefaultMinReportInterval
+	}
+	now := time.Now()
+	if now.Sub(self.lastReport) < interval {
+		return nil
+	}
+	self.lastReport = now
+
+	report := protocol.WorkDoneProgressReport{Kind: "report"}
+	percentage = min(percentage, 100)
+	report.Percentage = &percentage
+	if message != "" {
+		report.Message = &message
+	}
+	return self.notify(&report)
+}
+
+// Ends the progress and cancels Reporter.Context. The message can be empty.
+// Calling it more than once has no effect.
+func (self *Reporter) End(message string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.ended {
+		return nil
+	}
+	self.ended = true
+
+	defer self.cancel()
+	if self.token != nil {
+		contextRegistry(self.glspContext).remove(self.token)
+	}
+
+	if !self.begun {
+		return nil
+	}
+
+	end := protocol.WorkDoneProgressEnd{Kind: "end"}
+	if message != "" {
+		end.Message = &message
+	}
+	return self.notify(&end)
+}
+
+// Must be called while holding the lock
+func (self *Reporter) notify(value any) error {
+	if self.token == nil {
+		return nil
+	}
+
+	self.glspContext.Notify(string(protocol.MethodProgress), &protocol.ProgressParams{
+		Token: *self.token,
+		Value: value,
+	})
+	return nil
+}
@@ -2,8 +2,6 @@ package protocol
 
 import (
 	"encoding/json"
-	"strings"
-	"unicode/utf8"
 )
 
 // https://microsoft.github.io/language-server-protocol/specifications/specification-3-16#uri
@@ -52,77 +50,21 @@ type Position struct {
 	Character UInteger `json:"character"`
 }
 
+// Assumes UTF-16 position encoding.
+//
+// Deprecated: Use [Position.IndexInLineIndex] (or [Position.IndexInEncoding]
+// for a single conversion) with [GetContextPositionEncoding], which is correct
+// for any negotiated encoding.
 func (self Position) IndexIn(content string) int {
-	// This code is modified from the gopls implementation found:
-	// https://cs.opensource.google/go/x/tools/+/refs/tags/v0.1.5:internal/span/utf16.go;l=70
-
-	// In accordance with the LSP Spec:
-	// https://microsoft.github.io/language-server-protocol/specifications/specification-3-16#textDocuments
-	// self.Character represents utf-16 code units, not bytes and so we need to
-	// convert utf-16 code units to a byte offset.
-
-	// Find the byte offset for the line
-	index := 0
-	for row := UInteger(0); row < self.Line; row++ {
-		content_ := content[index:]
-		if next := strings.Index(content_, "\n"); next != -1 {
-			index += next + 1
-		} else {
-			return 0
-		}
-	}
-
-	// The index represents the byte offset from the beginning of the line
-	// count self.Character utf-16 code units from the index byte offset.
-
-	byteOffset := index
-	remains := content[index:]
-	chr := int(self.Character)
-
-	for count := 1; count <= chr; count++ {
-
-		if len(remains) <= 0 {
-			// char goes past content
-			// this a error
-			return 0
-		}
-
-		r, w := utf8.DecodeRuneInString(remains)
-		if r == '\n' {
-			// Per the LSP spec:
-			//
-			// > If the character value is greater than the line length it
-			// > defaults back to the line length.
-			break
-		}
-
-		remains = remains[w:]
-		if r >= 0x10000 {
-			// a two point rune
-			count++
-			// if we finished in a two point rune, do not advance past the first
-			if count > chr {
-				break
-			}
-		}
-		byteOffset += w
-
-	}
-
-	return byteOffset
+	return self.IndexInEncoding(content, PositionEncodingKindUTF16)
 }
 
+// Assumes UTF-16 position encoding.
+//
+// Deprecated: Use [Position.EndOfLineInEncoding] with
+// [GetContextPositionEncoding], which is correct for any negotiated encoding.
 func (self Position) EndOfLineIn(content string) Position {
-	index := self.IndexIn(content)
-	content_ := content[index:]
-	if eol := strings.Index(content_, "\n"); eol != -1 {
-		return Position{
-			Line:      self.Line,
-			Character: self.Character + UInteger(eol),
-		}
-	} else {
-		return self
-	}
+	return self.EndOfLineInEncoding(content, PositionEncodingKindUTF16)
 }
 
 // https://microsoft.github.io/language-server-protocol/specifications/specification-3-16#range
@@ -139,8 +81,25 @@ type Range struct {
 	End Position `json:"end"`
 }
 
+// Assumes UTF-16 position encoding.
+//
+// Deprecated: Use [Range.IndexesInLineIndex] (or [Range.IndexesInEncoding] for
+// a single conversion) with [GetContextPositionEncoding], which is correct for
+// any negotiated encoding.
 func (self Range) IndexesIn(content string) (int, int) {
-	return self.Start.IndexIn(content), self.End.IndexIn(content)
+	return self.IndexesInEncoding(content, PositionEncodingKindUTF16)
+}
+
+// See [Position.IndexInEncoding]. For many conversions in the same content use
+// [Range.IndexesInLineIndex] instead.
+func (self Range) IndexesInEncoding(content string, encoding PositionEncodingKind) (int, int) {
+	return self.Start.IndexInEncoding(content, encoding), self.End.IndexInEncoding(content, encoding)
+}
+
+// Like [Range.IndexesInEncoding] but in O(log n) using the index of the
+// content, e.g. from a document store. See [LineIndex.Indexes].
+func (self Range) IndexesInLineIndex(lineIndex *LineIndex, encoding PositionEncodingKind) (int, int) {
+	return lineIndex.Indexes(self, encoding)
 }
 
 // https://microsoft.github.io/language-server-protocol/specifications/specification-3-16#location
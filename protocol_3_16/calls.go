@@ -0,0 +1,76 @@
+package protocol
+
+import (
+	"github.com/tliron/glsp"
+)
+
+// Typed server-to-client calls. They use the [context.Context] of the
+// [glsp.Context], so use [glsp.Context.WithContext] to set a timeout. Errors
+// returned by the client will be of type *[glsp.Error].
+
+func ShowMessageRequest(context *glsp.Context, params *ShowMessageRequestParams) (*MessageActionItem, error) {
+	var result *MessageActionItem
+	if err := context.Call(context.Context, ServerWindowShowMessageRequest, params, &result); err == nil {
+		return result, nil
+	} else {
+		return nil, err
+	}
+}
+
+func ShowDocument(context *glsp.Context, params *ShowDocumentParams) (*ShowDocumentResult, error) {
+	var result ShowDocumentResult
+	if err := context.Call(context.Context, ServerWindowShowDocument, params, &result); err == nil {
+		return &result, nil
+	} else {
+		return nil, err
+	}
+}
+
+func WorkDoneProgressCreate(context *glsp.Context, params *WorkDoneProgressCreateParams) error {
+	return context.Call(context.Context, ServerWindowWorkDoneProgressCreate, params, nil)
+}
+
+func RegisterCapability(context *glsp.Context, params *RegistrationParams) error {
+	return context.Call(context.Context, ServerClientRegisterCapability, params, nil)
+}
+
+func UnregisterCapability(context *glsp.Context, params *UnregistrationParams) error {
+	return context.Call(context.Context, ServerClientUnregisterCapability, params, nil)
+}
+
+// Returns nil if no workspace folders are open.
+func WorkspaceFolders(context *glsp.Context) ([]WorkspaceFolder, error) {
+	var result []WorkspaceFolder
+	if err := context.Call(context.Context, ServerWorkspaceWorkspaceFolders, nil, &result); err == nil {
+		return result, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Returns one result per item in the params, in the same order.
+func Configuration(context *glsp.Context, params *ConfigurationParams) ([]any, error) {
+	var result []any
+	if err := context.Call(context.Context, ServerWorkspaceConfiguration, params, &result); err == nil {
+		return result, nil
+	} else {
+		return nil, err
+	}
+}
+
+func ApplyEdit(context *glsp.Context, params *ApplyWorkspaceEditParams) (*ApplyWorkspaceEditResponse, error) {
+	var result ApplyWorkspaceEditResponse
+	if err := context.Call(context.Context, ServerWorkspaceApplyEdit, params, &result); err == nil {
+		return &result, nil
+	} else {
+		return nil, err
+	}
+}
+
+func CodeLensRefresh(context *glsp.Context) error {
+	return context.Call(context.Context, ServerWorkspaceCodeLensRefresh, nil, nil)
+}
+
+func SemanticTokensRefresh(context *glsp.Context) error {
+	return context.Call(context.Context, MethodWorkspaceSemanticTokensRefresh, nil, nil)
+}
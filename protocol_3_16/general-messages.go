@@ -440,6 +440,14 @@ func (self *ClientCapabilities) SupportsSymbolKind(kind SymbolKind) bool {
 	}
 }
 
+func (self *ClientCapabilities) SupportsWorkDoneProgress() bool {
+	return (self.Window != nil) && (self.Window.WorkDoneProgress != nil) && *self.Window.WorkDoneProgress
+}
+
+func (self *ClientCapabilities) SupportsPublishDiagnosticsVersion() bool {
+	return (self.TextDocument != nil) && (self.TextDocument.PublishDiagnostics != nil) && (self.TextDocument.PublishDiagnostics.VersionSupport != nil) && *self.TextDocument.PublishDiagnostics.VersionSupport
+}
+
 type InitializeResult struct {
 	/**
 	 * The capabilities the language server provides.
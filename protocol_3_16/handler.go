@@ -2,7 +2,6 @@ package protocol
 
 import (
 	"encoding/json"
-	"errors"
 	"sync"
 
 	"github.com/tliron/glsp"
@@ -91,8 +90,8 @@ type Handler struct {
 
 // ([glsp.Handler] interface)
 func (self *Handler) Handle(context *glsp.Context) (r any, validMethod bool, validParams bool, err error) {
-	if !self.IsInitialized() && (context.Method != MethodInitialize) {
-		return nil, true, true, errors.New("server not initialized")
+	if !self.isInitialized(context) && (context.Method != MethodInitialize) {
+		return nil, true, true, &glsp.Error{Code: glsp.CodeServerNotInitialized, Message: "server not initialized"}
 	}
 
 	switch context.Method {
@@ -126,8 +125,14 @@ func (self *Handler) Handle(context *glsp.Context) (r any, validMethod bool, val
 			var params InitializeParams
 			if err = json.Unmarshal(context.Params, &params); err == nil {
 				validParams = true
+				if context.Session != nil {
+					context.Session.SetClientCapabilities(&params.Capabilities)
+				}
+				if params.Trace != nil {
+					SetContextTraceValue(context, *params.Trace)
+				}
 				if r, err = self.Initialize(context, &params); err == nil {
-					self.SetInitialized(true)
+					self.setInitialized(context, true)
 				}
 			}
 		}
@@ -143,10 +148,11 @@ func (self *Handler) Handle(context *glsp.Context) (r any, validMethod bool, val
 		}
 
 	case MethodShutdown:
-		self.SetInitialized(false)
+		// Clients expect all servers to support it
+		validMethod = true
+		validParams = true
+		self.setInitialized(context, false)
 		if self.Shutdown != nil {
-			validMethod = true
-			validParams = true
 			err = self.Shutdown(context)
 		}
 
@@ -169,11 +175,13 @@ func (self *Handler) Handle(context *glsp.Context) (r any, validMethod bool, val
 		}
 
 	case MethodSetTrace:
-		if self.SetTrace != nil {
-			validMethod = true
-			var params SetTraceParams
-			if err = json.Unmarshal(context.Params, &params); err == nil {
-				validParams = true
+		// We track the trace value for the session even if there is no handler func
+		validMethod = true
+		var params SetTraceParams
+		if err = json.Unmarshal(context.Params, &params); err == nil {
+			validParams = true
+			SetContextTraceValue(context, params.Value)
+			if self.SetTrace != nil {
 				err = self.SetTrace(context, &params)
 			}
 		}
@@ -740,6 +748,23 @@ func (self *Handler) SetInitialized(initialized bool) {
 	self.initialized = initialized
 }
 
+// Prefers the context's session, falling back to the handler's own state
+func (self *Handler) isInitialized(context *glsp.Context) bool {
+	if context.Session != nil {
+		return context.Session.IsInitialized()
+	} else {
+		return self.IsInitialized()
+	}
+}
+
+func (self *Handler) setInitialized(context *glsp.Context, initialized bool) {
+	if context.Session != nil {
+		context.Session.SetInitialized(initialized)
+	} else {
+		self.SetInitialized(initialized)
+	}
+}
+
 func (self *Handler) CreateServerCapabilities() ServerCapabilities {
 	var capabilities ServerCapabilities
 
@@ -0,0 +1,163 @@
+package protocol
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//
+// LineIndex
+//
+
+// An immutable index of the line starts in a content string, allowing for
+// conversion between positions and byte offsets in O(log n) for all position
+// encodings. Build it once per content, e.g. once per document version, and
+// use [LineIndex.Apply] to get an updated index after changes.
+//
+// Conversions follow the same rules as [Position.IndexInEncoding] and
+// [PositionFromIndex].
+type LineIndex struct {
+	content string
+	starts  []int  // byte offset at which each line starts
+	ascii   []bool // whether each line is ASCII only, in which case all encodings agree
+}
+
+func NewLineIndex(content string) *LineIndex {
+	self := LineIndex{content: content}
+	self.starts, self.ascii = indexLines(content, 0, nil, nil)
+	return &self
+}
+
+func (self *LineIndex) Content() string {
+	return self.content
+}
+
+func (self *LineIndex) LineCount() int {
+	return len(self.starts)
+}
+
+// Returns the byte offset of the position in the content. See
+// [Position.IndexInEncoding].
+func (self *LineIndex) Index(position Position, encoding PositionEncodingKind) int {
+	if int64(position.Line) >= int64(len(self.starts)) {
+		return len(self.content)
+	}
+
+	start := self.starts[position.Line]
+	if self.ascii[position.Line] {
+		return start + min(int(position.Character), self.lineLength(int(position.Line)))
+	} else {
+		return start + lineIndex(self.content[start:], position.Character, encoding)
+	}
+}
+
+// Returns the byte offsets of the range's start and end in the content.
+func (self *LineIndex) Indexes(range_ Range, encoding PositionEncodingKind) (int, int) {
+	return self.Index(range_.Start, encoding), self.Index(range_.End, encoding)
+}
+
+// The reverse of [LineIndex.Index]. See [PositionFromIndex].
+func (self *LineIndex) Position(index int, encoding PositionEncodingKind) Position {
+	index = min(max(index, 0), len(self.content))
+
+	// The last line that starts at or before the index
+	line := sort.Search(len(self.starts), func(line int) bool {
+		return self.starts[line] > index
+	}) - 1
+
+	start := self.starts[line]
+	if self.ascii[line] {
+		return Position{Line: UInteger(line), Character: UInteger(index - start)}
+	} else {
+		return Position{Line: UInteger(line), Character: codeUnits(self.content[start:index], encoding)}
+	}
+}
+
+// Returns a new index for the content after the change is applied. Only the
+// lines touched by the change are re-indexed. The change is either a
+// [TextDocumentContentChangeEvent] or a [TextDocumentContentChangeEventWhole].
+func (self *LineIndex) Apply(change any, encoding PositionEncodingKind) (*LineIndex, error) {
+	switch change_ := change.(type) {
+	case TextDocumentContentChangeEvent:
+		if change_.Range == nil {
+			return NewLineIndex(change_.Text), nil
+		}
+
+		start, end := self.Indexes(*change_.Range, encoding)
+		if start > end {
+			return nil, fmt.Errorf("invalid change range: %d > %d", start, end)
+		}
+		return self.replace(start, end, change_.Text), nil
+
+	case TextDocumentContentChangeEventWhole:
+		return NewLineIndex(change_.Text), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported content change type: %T", change)
+	}
+}
+
+func (self *LineIndex) replace(start int, end int, text string) *LineIndex {
+	startLine := int(self.Position(start, PositionEncodingKindUTF8).Line)
+	endLine := int(self.Position(end, PositionEncodingKindUTF8).Line)
+	delta := len(text) - (end - start)
+
+	index := LineIndex{content: self.content[:start] + text + self.content[end:]}
+
+	// Keep the lines before the change and re-index the lines it touches
+	index.starts = make([]int, startLine, len(self.starts)+strings.Count(text, "\n"))
+	index.ascii = make([]bool, startLine, cap(index.starts))
+	copy(index.starts, self.starts[:startLine])
+	copy(index.ascii, self.ascii[:startLine])
+
+	touchedEnd := len(index.content)
+	if endLine+1 < len(self.starts) {
+		touchedEnd = self.starts[endLine+1] + delta
+	}
+	index.starts, index.ascii = indexLines(index.content[:touchedEnd], self.starts[startLine], index.starts, index.ascii)
+
+	// Shift the lines after the change
+	if endLine+1 < len(self.starts) {
+		// The touched content ended with a "\n", which indexLines counted as
+		// the start of an empty line that is actually the next line
+		index.starts = index.starts[:len(index.starts)-1]
+		index.ascii = index.ascii[:len(index.ascii)-1]
+
+		for line := endLine + 1; line < len(self.starts); line++ {
+			index.starts = append(index.starts, self.starts[line]+delta)
+			index.ascii = append(index.ascii, self.ascii[line])
+		}
+	}
+
+	return &index
+}
+
+// Length in bytes, not including the "\n"
+func (self *LineIndex) lineLength(line int) int {
+	if line+1 < len(self.starts) {
+		return self.starts[line+1] - self.starts[line] - 1
+	} else {
+		return len(self.content) - self.starts[line]
+	}
+}
+
+// Appends the lines of the content from the offset on. There is always at
+// least one line, even if empty.
+func indexLines(content string, offset int, starts []int, ascii []bool) ([]int, []bool) {
+	start := offset
+	ascii_ := true
+	for index := offset; index < len(content); index++ {
+		switch c := content[index]; {
+		case c == '\n':
+			starts = append(starts, start)
+			ascii = append(ascii, ascii_)
+			start = index + 1
+			ascii_ = true
+
+		case c >= 0x80:
+			ascii_ = false
+		}
+	}
+	return append(starts, start), append(ascii, ascii_)
+}
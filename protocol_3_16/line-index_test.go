@@ -0,0 +1,87 @@
+package protocol
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestLineIndexReplace(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		start   int
+		end     int
+		text    string
+	}{
+		{"insert into empty", "", 0, 0, "hello"},
+		{"insert line break into empty", "", 0, 0, "\n"},
+		{"insert within line", "one\ntwo\nthree", 5, 5, "XX"},
+		{"insert line breaks within line", "one\ntwo\nthree", 5, 5, "X\nY\nZ"},
+		{"insert at start", "one\ntwo", 0, 0, "zero\n"},
+		{"insert at end", "one\ntwo", 7, 7, "\nthree"},
+		{"insert after trailing line break", "one\n", 4, 4, "two"},
+		{"delete line break", "one\ntwo\nthree", 3, 4, ""},
+		{"delete across lines", "one\ntwo\nthree\nfour", 2, 12, ""},
+		{"delete everything", "one\ntwo", 0, 7, ""},
+		{"replace last line", "one\ntwo", 4, 7, "2\n3"},
+		{"replace whole line with its line break", "one\ntwo\nthree", 4, 8, ""},
+		{"make line non-ASCII", "one\ntwo\nthree", 5, 6, "ü"},
+		{"make line ASCII", "one\ntwü\nthree", 6, 8, "o"},
+		{"non-ASCII line break", "a😀\nb", 1, 5, "\n\n"},
+		{"carriage return", "one\r\ntwo", 3, 5, "\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectLineIndex(t, NewLineIndex(test.content).replace(test.start, test.end, test.text), test.content[:test.start]+test.text+test.content[test.end:])
+		})
+	}
+}
+
+func TestLineIndexReplaceRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	pieces := []string{"", "a", "bc", "\n", "\n\n", "ü", "😀", "x\ny", "\r\n"}
+
+	lineIndex := NewLineIndex("")
+	for range 5000 {
+		content := lineIndex.Content()
+		start := random.Intn(len(content) + 1)
+		end := start + random.Intn(len(content)-start+1)
+		text := pieces[random.Intn(len(pieces))] + pieces[random.Intn(len(pieces))]
+
+		lineIndex = lineIndex.replace(start, end, text)
+		if !expectLineIndex(t, lineIndex, content[:start]+text+content[end:]) {
+			t.Fatalf("after replacing %d to %d in %q with %q", start, end, content, text)
+		}
+	}
+}
+
+func TestLineIndexApply(t *testing.T) {
+	content := "one\ntwü 😀 three\nfour"
+	lineIndex := NewLineIndex(content)
+
+	for _, encoding := range []PositionEncodingKind{PositionEncodingKindUTF8, PositionEncodingKindUTF16, PositionEncodingKindUTF32} {
+		range_ := Range{Start: Position{Line: 1, Character: 2}, End: Position{Line: 2, Character: 1}}
+		start, end := range_.IndexesInEncoding(content, encoding)
+		if start_, end_ := range_.IndexesInLineIndex(lineIndex, encoding); (start_ != start) || (end_ != end) {
+			t.Errorf("%s: expected %d to %d, got %d to %d", encoding, start, end, start_, end_)
+		}
+
+		applied, err := lineIndex.Apply(TextDocumentContentChangeEvent{Range: &range_, Text: "X\n"}, encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectLineIndex(t, applied, content[:start]+"X\n"+content[end:])
+	}
+}
+
+func expectLineIndex(t *testing.T, lineIndex *LineIndex, content string) bool {
+	t.Helper()
+	expected := NewLineIndex(content)
+	if !reflect.DeepEqual(lineIndex, expected) {
+		t.Errorf("expected %+v, got %+v", expected, lineIndex)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,169 @@
+package protocol
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tliron/glsp"
+)
+
+// Position encodings were only introduced in the 3.17 spec, but because
+// [Position] is defined here we need them here, too. protocol_3_17 refers to
+// these.
+
+/**
+ * A type indicating how positions are encoded,
+ * specifically what column offsets mean.
+ *
+ * @since 3.17.0
+ */
+type PositionEncodingKind string
+
+const (
+	/**
+	 * Character offsets count UTF-8 code units (e.g bytes).
+	 */
+	PositionEncodingKindUTF8 PositionEncodingKind = "utf-8"
+
+	/**
+	 * Character offsets count UTF-16 code units.
+	 *
+	 * This is the default and must always be supported
+	 * by servers
+	 */
+	PositionEncodingKindUTF16 PositionEncodingKind = "utf-16"
+
+	/**
+	 * Character offsets count UTF-32 code units.
+	 *
+	 * Implementation note: these are the same as Unicode code points,
+	 * so this `PositionEncodingKind` may also be used for an
+	 * encoding-agnostic representation of character offsets.
+	 */
+	PositionEncodingKindUTF32 PositionEncodingKind = "utf-32"
+)
+
+// Returns the position encoding negotiated for the context's session, which
+// defaults to UTF-16.
+func GetContextPositionEncoding(context *glsp.Context) PositionEncodingKind {
+	if context.Session != nil {
+		if encoding := context.Session.GetPositionEncoding(); encoding != "" {
+			return PositionEncodingKind(encoding)
+		}
+	}
+	return PositionEncodingKindUTF16
+}
+
+// Returns the byte offset of the position in the content. Only "\n" is
+// considered a line break. Per the spec, a character beyond the line length
+// defaults back to the line length. Likewise, a line beyond the content
+// defaults to the end of the content.
+//
+// This scans the content up to the position. For many conversions in the same
+// content use [Position.IndexInLineIndex] instead.
+func (self Position) IndexInEncoding(content string, encoding PositionEncodingKind) int {
+	// Find the byte offset of the line
+	index := 0
+	for line := UInteger(0); line < self.Line; line++ {
+		if next := strings.IndexByte(content[index:], '\n'); next != -1 {
+			index += next + 1
+		} else {
+			return len(content)
+		}
+	}
+
+	// Count code units until we reach the character
+	return index + lineIndex(content[index:], self.Character, encoding)
+}
+
+// Like [Position.IndexInEncoding] but in O(log n) using the index of the
+// content, e.g. from a document store. See [LineIndex.Index].
+func (self Position) IndexInLineIndex(lineIndex *LineIndex, encoding PositionEncodingKind) int {
+	return lineIndex.Index(self, encoding)
+}
+
+func (self Position) EndOfLineInEncoding(content string, encoding PositionEncodingKind) Position {
+	index := self.IndexInEncoding(content, encoding)
+	start := strings.LastIndexByte(content[:index], '\n') + 1
+	end := strings.IndexByte(content[index:], '\n')
+	if end == -1 {
+		end = len(content)
+	} else {
+		end += index
+	}
+
+	return Position{
+		Line:      self.Line,
+		Character: codeUnits(content[start:end], encoding),
+	}
+}
+
+// The reverse of [Position.IndexInEncoding]. An index beyond the content
+// defaults to the end of the content.
+func PositionFromIndex(content string, index int, encoding PositionEncodingKind) Position {
+	index = min(max(index, 0), len(content))
+	start := strings.LastIndexByte(content[:index], '\n') + 1
+	return Position{
+		Line:      UInteger(strings.Count(content[:start], "\n")),
+		Character: codeUnits(content[start:index], encoding),
+	}
+}
+
+// Returns the byte offset of the character in the line (which may continue
+// beyond the first "\n").
+func lineIndex(line string, character UInteger, encoding PositionEncodingKind) int {
+	index := 0
+	units := UInteger(0)
+	for (units < character) && (index < len(line)) {
+		rune_, width := utf8.DecodeRuneInString(line[index:])
+		if rune_ == '\n' {
+			break
+		}
+
+		// We will not advance into the middle of a rune
+		units += runeCodeUnits(rune_, width, encoding)
+		if units > character {
+			break
+		}
+
+		index += width
+	}
+	return index
+}
+
+func codeUnits(content string, encoding PositionEncodingKind) UInteger {
+	switch encoding {
+	case PositionEncodingKindUTF8:
+		return UInteger(len(content))
+
+	case PositionEncodingKindUTF32:
+		return UInteger(utf8.RuneCountInString(content))
+
+	default:
+		units := UInteger(0)
+		for index := 0; index < len(content); {
+			rune_, width := utf8.DecodeRuneInString(content[index:])
+			units += runeCodeUnits(rune_, width, encoding)
+			index += width
+		}
+		return units
+	}
+}
+
+func runeCodeUnits(rune_ rune, width int, encoding PositionEncodingKind) UInteger {
+	switch encoding {
+	case PositionEncodingKindUTF8:
+		return UInteger(width)
+
+	case PositionEncodingKindUTF32:
+		return 1
+
+	default:
+		// UTF-16 needs a surrogate pair for runes outside the Basic Multilingual Plane
+		if rune_ >= 0x10000 {
+			return 2
+		} else {
+			return 1
+		}
+	}
+}
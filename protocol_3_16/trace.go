@@ -19,18 +19,69 @@ func GetTraceValue() TraceValue {
 func SetTraceValue(value TraceValue) {
 	traceValueLock.Lock()
 	defer traceValueLock.Unlock()
+	traceValue = normalizeTraceValue(value)
+}
 
-	// The spec clearly says "message", but some implementations use "messages" instead
-	if value == "messages" {
-		value = TraceValueMessage
+// Returns the trace value of the context's session, falling back to the
+// global trace value if the context has no session.
+func GetContextTraceValue(context *glsp.Context) TraceValue {
+	if context.Session != nil {
+		if value := context.Session.GetTrace(); value != "" {
+			return TraceValue(value)
+		} else {
+			return TraceValueOff
+		}
+	} else {
+		return GetTraceValue()
 	}
+}
 
-	traceValue = value
+// Sets the trace value of the context's session, falling back to the global
+// trace value if the context has no session.
+func SetContextTraceValue(context *glsp.Context, value TraceValue) {
+	if context.Session != nil {
+		context.Session.SetTrace(string(normalizeTraceValue(value)))
+	} else {
+		SetTraceValue(value)
+	}
 }
 
 func HasTraceLevel(value TraceValue) bool {
-	value_ := GetTraceValue()
-	switch value_ {
+	return hasTraceLevel(GetTraceValue(), value)
+}
+
+func HasContextTraceLevel(context *glsp.Context, value TraceValue) bool {
+	return hasTraceLevel(GetContextTraceValue(context), value)
+}
+
+func HasTraceMessageType(type_ MessageType) bool {
+	return HasTraceLevel(traceLevelFor(type_))
+}
+
+func HasContextTraceMessageType(context *glsp.Context, type_ MessageType) bool {
+	return HasContextTraceLevel(context, traceLevelFor(type_))
+}
+
+func Trace(context *glsp.Context, type_ MessageType, message string) error {
+	if HasContextTraceMessageType(context, type_) {
+		go context.Notify(ServerWindowLogMessage, &LogMessageParams{
+			Type:    type_,
+			Message: message,
+		})
+	}
+	return nil
+}
+
+func normalizeTraceValue(value TraceValue) TraceValue {
+	// The spec clearly says "message", but some implementations use "messages" instead
+	if value == "messages" {
+		return TraceValueMessage
+	}
+	return value
+}
+
+func hasTraceLevel(current TraceValue, value TraceValue) bool {
+	switch current {
 	case TraceValueOff:
 		return false
 
@@ -41,29 +92,19 @@ func HasTraceLevel(value TraceValue) bool {
 		return true
 
 	default:
-		panic(fmt.Sprintf("unsupported trace level: %s", value_))
+		panic(fmt.Sprintf("unsupported trace level: %s", current))
 	}
 }
 
-func HasTraceMessageType(type_ MessageType) bool {
+func traceLevelFor(type_ MessageType) TraceValue {
 	switch type_ {
 	case MessageTypeError, MessageTypeWarning, MessageTypeInfo:
-		return HasTraceLevel(TraceValueMessage)
+		return TraceValueMessage
 
 	case MessageTypeLog:
-		return HasTraceLevel(TraceValueVerbose)
+		return TraceValueVerbose
 
 	default:
 		panic(fmt.Sprintf("unsupported message type: %d", type_))
 	}
 }
-
-func Trace(context *glsp.Context, type_ MessageType, message string) error {
-	if HasTraceMessageType(type_) {
-		go context.Notify(ServerWindowLogMessage, &LogMessageParams{
-			Type:    type_,
-			Message: message,
-		})
-	}
-	return nil
-}
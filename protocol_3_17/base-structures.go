@@ -92,13 +92,13 @@ const (
  *
  * @since 3.17.0
  */
-type PositionEncodingKind string
+type PositionEncodingKind = protocol316.PositionEncodingKind
 
 const (
 	/**
 	 * Character offsets count UTF-8 code units (e.g bytes).
 	 */
-	PositionEncodingKindUTF8 PositionEncodingKind = "utf-8"
+	PositionEncodingKindUTF8 = protocol316.PositionEncodingKindUTF8
 
 	/**
 	 * Character offsets count UTF-16 code units.
@@ -106,7 +106,7 @@ const (
 	 * This is the default and must always be supported
 	 * by servers
 	 */
-	PositionEncodingKindUTF16 PositionEncodingKind = "utf-16"
+	PositionEncodingKindUTF16 = protocol316.PositionEncodingKindUTF16
 
 	/**
 	 * Character offsets count UTF-32 code units.
@@ -115,5 +115,5 @@ const (
 	 * so this `PositionEncodingKind` may also be used for an
 	 * encoding-agnostic representation of character offsets.
 	 */
-	PositionEncodingKindUTF32 PositionEncodingKind = "utf-32"
+	PositionEncodingKindUTF32 = protocol316.PositionEncodingKindUTF32
 )
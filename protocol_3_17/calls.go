@@ -0,0 +1,19 @@
+package protocol
+
+import (
+	"github.com/tliron/glsp"
+)
+
+// Typed server-to-client calls. See also the ones in protocol_3_16.
+
+func InlayHintRefresh(context *glsp.Context) error {
+	return context.Call(context.Context, MethodWorkspaceInlayHintRefresh, nil, nil)
+}
+
+func InlineValueRefresh(context *glsp.Context) error {
+	return context.Call(context.Context, MethodWorkspaceInlineValueRefresh, nil, nil)
+}
+
+func DiagnosticRefresh(context *glsp.Context) error {
+	return context.Call(context.Context, MethodWorkspaceDiagnosticRefresh, nil, nil)
+}
@@ -225,3 +225,133 @@ type DocumentDiagnosticReportPartialResult struct {
 type DiagnosticServerCancellationData struct {
 	RetriggerRequest bool `json:"retriggerRequest"`
 }
+
+/**
+ * Workspace client capabilities specific to diagnostic pull requests.
+ *
+ * @since 3.17.0
+ */
+type DiagnosticWorkspaceClientCapabilities struct {
+	/**
+	 * Whether the client implementation supports a refresh request sent from
+	 * the server to the client.
+	 *
+	 * Note that this event is global and will force the client to refresh all
+	 * pulled diagnostics currently shown. It should be used with absolute care
+	 * and is useful for situation where a server for example detects a project
+	 * wide change that requires such a calculation.
+	 */
+	RefreshSupport *bool `json:"refreshSupport,omitempty"`
+}
+
+const MethodWorkspaceDiagnostic = protocol316.Method("workspace/diagnostic")
+
+type WorkspaceDiagnosticFunc func(context *glsp.Context, params *WorkspaceDiagnosticParams) (*WorkspaceDiagnosticReport, error)
+
+/**
+ * Parameters of the workspace diagnostic request.
+ *
+ * @since 3.17.0
+ */
+type WorkspaceDiagnosticParams struct {
+	protocol316.WorkDoneProgressParams
+	protocol316.PartialResultParams
+
+	/**
+	 * The additional identifier provided during registration.
+	 */
+	Identifier *string `json:"identifier,omitempty"`
+
+	/**
+	 * The currently known diagnostic reports with their
+	 * previous result ids.
+	 */
+	PreviousResultIds []PreviousResultId `json:"previousResultIds"`
+}
+
+/**
+ * A previous result id in a workspace pull request.
+ *
+ * @since 3.17.0
+ */
+type PreviousResultId struct {
+	/**
+	 * The URI for which the client knows a
+	 * result id.
+	 */
+	URI protocol316.DocumentUri `json:"uri"`
+
+	/**
+	 * The value of the previous result id.
+	 */
+	Value string `json:"value"`
+}
+
+/**
+ * A workspace diagnostic report.
+ *
+ * @since 3.17.0
+ */
+type WorkspaceDiagnosticReport struct {
+	Items []WorkspaceDocumentDiagnosticReport `json:"items"`
+}
+
+/**
+ * A partial result for a workspace diagnostic report.
+ *
+ * @since 3.17.0
+ */
+type WorkspaceDiagnosticReportPartialResult struct {
+	Items []WorkspaceDocumentDiagnosticReport `json:"items"`
+}
+
+/**
+ * A workspace diagnostic document report.
+ *
+ * @since 3.17.0
+ */
+type WorkspaceDocumentDiagnosticReport any // WorkspaceFullDocumentDiagnosticReport | WorkspaceUnchangedDocumentDiagnosticReport
+
+/**
+ * A full document diagnostic report for a workspace diagnostic result.
+ *
+ * @since 3.17.0
+ */
+type WorkspaceFullDocumentDiagnosticReport struct {
+	FullDocumentDiagnosticReport
+
+	/**
+	 * The URI for which diagnostic information is reported.
+	 */
+	URI protocol316.DocumentUri `json:"uri"`
+
+	/**
+	 * The version number for which the diagnostics are reported.
+	 * If the document is not marked as open `null` can be provided.
+	 */
+	Version *protocol316.Integer `json:"version"`
+}
+
+/**
+ * An unchanged document diagnostic report for a workspace diagnostic result.
+ *
+ * @since 3.17.0
+ */
+type WorkspaceUnchangedDocumentDiagnosticReport struct {
+	UnchangedDocumentDiagnosticReport
+
+	/**
+	 * The URI for which diagnostic information is reported.
+	 */
+	URI protocol316.DocumentUri `json:"uri"`
+
+	/**
+	 * The version number for which the diagnostics are reported.
+	 * If the document is not marked as open `null` can be provided.
+	 */
+	Version *protocol316.Integer `json:"version"`
+}
+
+const MethodWorkspaceDiagnosticRefresh = protocol316.Method("workspace/diagnostic/refresh")
+
+type WorkspaceDiagnosticRefreshFunc func(context *glsp.Context) error
@@ -39,6 +39,19 @@ type ClientCapabilities struct {
 	Workspace *WorkspaceClientCapabilities `json:"workspace,omitempty"`
 
 	TextDocument *TextDocumentClientCapabilities `json:"textDocument,omitempty"`
+
+	/**
+	 * Capabilities specific to the notebook document support.
+	 *
+	 * @since 3.17.0
+	 */
+	NotebookDocument *NotebookDocumentClientCapabilities `json:"notebookDocument,omitempty"`
+}
+
+// Overrides the embedded protocol316.ClientCapabilities method, because our
+// TextDocument field shadows its field.
+func (self *ClientCapabilities) SupportsPublishDiagnosticsVersion() bool {
+	return (self.TextDocument != nil) && (self.TextDocument.PublishDiagnostics != nil) && (self.TextDocument.PublishDiagnostics.VersionSupport != nil) && *self.TextDocument.PublishDiagnostics.VersionSupport
 }
 
 /**
@@ -60,6 +73,13 @@ type WorkspaceClientCapabilities struct {
 	 * @since 3.17.0
 	 */
 	InlineValue *InlineValueWorkspaceClientCapabilities `json:"inlineValue,omitempty"`
+
+	/**
+	 * Client workspace capabilities specific to diagnostics.
+	 *
+	 * @since 3.17.0
+	 */
+	Diagnostics *DiagnosticWorkspaceClientCapabilities `json:"diagnostics,omitempty"`
 }
 
 /**
@@ -165,6 +185,26 @@ type TextDocumentClientCapabilities struct {
 type ServerCapabilities struct {
 	protocol316.ServerCapabilities
 
+	/**
+	 * The position encoding the server picked from the encodings offered
+	 * by the client via the client capability `general.positionEncodings`.
+	 *
+	 * If the client didn't provide any position encodings the only valid
+	 * value that a server can return is 'utf-16'.
+	 *
+	 * If omitted it defaults to 'utf-16'.
+	 *
+	 * @since 3.17.0
+	 */
+	PositionEncoding *PositionEncodingKind `json:"positionEncoding,omitempty"`
+
+	/**
+	 * Defines how notebook documents are synced.
+	 *
+	 * @since 3.17.0
+	 */
+	NotebookDocumentSync any `json:"notebookDocumentSync,omitempty"` // nil | NotebookDocumentSyncOptions | NotebookDocumentSyncRegistrationOptions
+
 	/**
 	 * The server has support for pull model diagnostics.
 	 *
@@ -196,7 +236,9 @@ type ServerCapabilities struct {
 
 func (self *ServerCapabilities) UnmarshalJSON(data []byte) error {
 	var value struct {
-		TextDocumentSync                 json.RawMessage                              `json:"textDocumentSync,omitempty"` // nil | TextDocumentSyncOptions | TextDocumentSyncKind
+		PositionEncoding                 *PositionEncodingKind                        `json:"positionEncoding,omitempty"`
+		TextDocumentSync                 json.RawMessage                              `json:"textDocumentSync,omitempty"`     // nil | TextDocumentSyncOptions | TextDocumentSyncKind
+		NotebookDocumentSync             json.RawMessage                              `json:"notebookDocumentSync,omitempty"` // nil | NotebookDocumentSyncOptions | NotebookDocumentSyncRegistrationOptions
 		CompletionProvider               *protocol316.CompletionOptions               `json:"completionProvider,omitempty"`
 		HoverProvider                    json.RawMessage                              `json:"hoverProvider,omitempty"` // nil | bool | HoverOptions
 		SignatureHelpProvider            *protocol316.SignatureHelpOptions            `json:"signatureHelpProvider,omitempty"`
@@ -232,6 +274,7 @@ func (self *ServerCapabilities) UnmarshalJSON(data []byte) error {
 	}
 
 	if err := json.Unmarshal(data, &value); err == nil {
+		self.PositionEncoding = value.PositionEncoding
 		self.CompletionProvider = value.CompletionProvider
 		self.SignatureHelpProvider = value.SignatureHelpProvider
 		self.CodeLensProvider = value.CodeLensProvider
@@ -254,6 +297,19 @@ func (self *ServerCapabilities) UnmarshalJSON(data []byte) error {
 			}
 		}
 
+		if value.NotebookDocumentSync != nil {
+			var value_ NotebookDocumentSyncRegistrationOptions
+			if err = json.Unmarshal(value.NotebookDocumentSync, &value_); err == nil {
+				if value_.ID != nil {
+					self.NotebookDocumentSync = value_
+				} else {
+					self.NotebookDocumentSync = value_.NotebookDocumentSyncOptions
+				}
+			} else {
+				return err
+			}
+		}
+
 		if value.HoverProvider != nil {
 			var value_ bool
 			if err = json.Unmarshal(value.HoverProvider, &value_); err == nil {
@@ -669,16 +725,9 @@ type InitializeResult struct {
 	 */
 	ServerInfo *protocol316.InitializeResultServerInfo `json:"serverInfo,omitempty"`
 
-	/**
-	 * The position encoding the server picked from the encodings offered
-	 * by the client via the client capability `general.positionEncodings`.
-	 *
-	 * If the client didn't provide any position encodings the only valid
-	 * value that a server can return is 'utf-16'.
-	 *
-	 * If omitted it defaults to 'utf-16'.
-	 *
-	 * @since 3.17.0
-	 */
+	// The spec puts it in the server capabilities, but we are keeping this
+	// here for backwards compatibility. Clients will ignore it.
+	//
+	// Deprecated: Use Capabilities.PositionEncoding instead.
 	PositionEncoding *PositionEncodingKind `json:"positionEncoding,omitempty"`
 }
@@ -2,7 +2,6 @@ package protocol
 
 import (
 	"encoding/json"
-	"errors"
 	"sync"
 
 	"github.com/tliron/glsp"
@@ -15,8 +14,22 @@ type Handler struct {
 	// General Messages (3.17 version)
 	Initialize InitializeFunc
 
+	// In order of preference; when nil only UTF-16 is used. See [UTF8PositionEncodings]
+	PositionEncodings []PositionEncodingKind
+
+	// Notebook Document Synchronization
+	NotebookDocumentDidOpen   NotebookDocumentDidOpenFunc
+	NotebookDocumentDidChange NotebookDocumentDidChangeFunc
+	NotebookDocumentDidSave   NotebookDocumentDidSaveFunc
+	NotebookDocumentDidClose  NotebookDocumentDidCloseFunc
+
+	// The notebooks to be synced; defaults to all notebooks
+	NotebookSelector []NotebookDocumentSyncOptionsSelector
+
 	// Pull Diagnostics
-	TextDocumentDiagnostic TextDocumentDiagnosticFunc
+	TextDocumentDiagnostic     TextDocumentDiagnosticFunc
+	WorkspaceDiagnostic        WorkspaceDiagnosticFunc
+	WorkspaceDiagnosticRefresh WorkspaceDiagnosticRefreshFunc
 
 	// Type Hierarchy
 	TextDocumentPrepareTypeHierarchy TextDocumentPrepareTypeHierarchyFunc
@@ -37,8 +50,8 @@ type Handler struct {
 }
 
 func (self *Handler) Handle(context *glsp.Context) (r any, validMethod bool, validParams bool, err error) {
-	if !self.IsInitialized() && (context.Method != protocol316.MethodInitialize) {
-		return nil, true, true, errors.New("server not initialized")
+	if !self.isInitialized(context) && (context.Method != protocol316.MethodInitialize) {
+		return nil, true, true, &glsp.Error{Code: glsp.CodeServerNotInitialized, Message: "server not initialized"}
 	}
 
 	switch context.Method {
@@ -70,8 +83,16 @@ func (self *Handler) Handle(context *glsp.Context) (r any, validMethod bool, val
 			var params InitializeParams
 			if err = json.Unmarshal(context.Params, &params); err == nil {
 				validParams = true
+				if context.Session != nil {
+					context.Session.SetClientCapabilities(&params.Capabilities)
+				}
+				if params.Trace != nil {
+					protocol316.SetContextTraceValue(context, *params.Trace)
+				}
+				encoding := self.negotiatePositionEncoding(context, &params)
 				if r, err = self.Initialize(context, &params); err == nil {
-					self.SetInitialized(true)
+					r = self.setPositionEncoding(context, r, encoding)
+					self.setInitialized(context, true)
 				}
 			}
 		}
@@ -87,10 +108,11 @@ func (self *Handler) Handle(context *glsp.Context) (r any, validMethod bool, val
 		}
 
 	case protocol316.MethodShutdown:
-		self.SetInitialized(false)
+		// Clients expect all servers to support it
+		validMethod = true
+		validParams = true
+		self.setInitialized(context, false)
 		if self.Shutdown != nil {
-			validMethod = true
-			validParams = true
 			err = self.Shutdown(context)
 		}
 
@@ -113,11 +135,13 @@ func (self *Handler) Handle(context *glsp.Context) (r any, validMethod bool, val
 		}
 
 	case protocol316.MethodSetTrace:
-		if self.SetTrace != nil {
-			validMethod = true
-			var params protocol316.SetTraceParams
-			if err = json.Unmarshal(context.Params, &params); err == nil {
-				validParams = true
+		// We track the trace value for the session even if there is no handler func
+		validMethod = true
+		var params protocol316.SetTraceParams
+		if err = json.Unmarshal(context.Params, &params); err == nil {
+			validParams = true
+			protocol316.SetContextTraceValue(context, params.Value)
+			if self.SetTrace != nil {
 				err = self.SetTrace(context, &params)
 			}
 		}
@@ -308,6 +332,48 @@ func (self *Handler) Handle(context *glsp.Context) (r any, validMethod bool, val
 			}
 		}
 
+	// Notebook Document Synchronization
+
+	case MethodNotebookDocumentDidOpen:
+		if self.NotebookDocumentDidOpen != nil {
+			validMethod = true
+			var params DidOpenNotebookDocumentParams
+			if err = json.Unmarshal(context.Params, &params); err == nil {
+				validParams = true
+				err = self.NotebookDocumentDidOpen(context, &params)
+			}
+		}
+
+	case MethodNotebookDocumentDidChange:
+		if self.NotebookDocumentDidChange != nil {
+			validMethod = true
+			var params DidChangeNotebookDocumentParams
+			if err = json.Unmarshal(context.Params, &params); err == nil {
+				validParams = true
+				err = self.NotebookDocumentDidChange(context, &params)
+			}
+		}
+
+	case MethodNotebookDocumentDidSave:
+		if self.NotebookDocumentDidSave != nil {
+			validMethod = true
+			var params DidSaveNotebookDocumentParams
+			if err = json.Unmarshal(context.Params, &params); err == nil {
+				validParams = true
+				err = self.NotebookDocumentDidSave(context, &params)
+			}
+		}
+
+	case MethodNotebookDocumentDidClose:
+		if self.NotebookDocumentDidClose != nil {
+			validMethod = true
+			var params DidCloseNotebookDocumentParams
+			if err = json.Unmarshal(context.Params, &params); err == nil {
+				validParams = true
+				err = self.NotebookDocumentDidClose(context, &params)
+			}
+		}
+
 	// Language Features
 
 	case protocol316.MethodTextDocumentCompletion:
@@ -666,6 +732,23 @@ func (self *Handler) Handle(context *glsp.Context) (r any, validMethod bool, val
 			}
 		}
 
+	case MethodWorkspaceDiagnostic:
+		if self.WorkspaceDiagnostic != nil {
+			validMethod = true
+			var params WorkspaceDiagnosticParams
+			if err = json.Unmarshal(context.Params, &params); err == nil {
+				validParams = true
+				r, err = self.WorkspaceDiagnostic(context, &params)
+			}
+		}
+
+	case MethodWorkspaceDiagnosticRefresh:
+		if self.WorkspaceDiagnosticRefresh != nil {
+			validMethod = true
+			validParams = true
+			err = self.WorkspaceDiagnosticRefresh(context)
+		}
+
 	// Type Hierarchy
 
 	case MethodTextDocumentPrepareTypeHierarchy:
@@ -774,6 +857,23 @@ func (self *Handler) SetInitialized(initialized bool) {
 	self.initialized = initialized
 }
 
+// Prefers the context's session, falling back to the handler's own state
+func (self *Handler) isInitialized(context *glsp.Context) bool {
+	if context.Session != nil {
+		return context.Session.IsInitialized()
+	} else {
+		return self.IsInitialized()
+	}
+}
+
+func (self *Handler) setInitialized(context *glsp.Context, initialized bool) {
+	if context.Session != nil {
+		context.Session.SetInitialized(initialized)
+	} else {
+		self.SetInitialized(initialized)
+	}
+}
+
 func (self *Handler) CreateServerCapabilities() ServerCapabilities {
 	var capabilities ServerCapabilities
 
@@ -814,6 +914,17 @@ func (self *Handler) CreateServerCapabilities() ServerCapabilities {
 		capabilities.TextDocumentSync.(*protocol316.TextDocumentSyncOptions).Save = &protocol316.True
 	}
 
+	if (self.NotebookDocumentDidOpen != nil) || (self.NotebookDocumentDidChange != nil) || (self.NotebookDocumentDidSave != nil) || (self.NotebookDocumentDidClose != nil) {
+		options := &NotebookDocumentSyncOptions{NotebookSelector: self.NotebookSelector}
+		if options.NotebookSelector == nil {
+			options.NotebookSelector = []NotebookDocumentSyncOptionsSelector{{Notebook: "*"}}
+		}
+		if self.NotebookDocumentDidSave != nil {
+			options.Save = &protocol316.True
+		}
+		capabilities.NotebookDocumentSync = options
+	}
+
 	if self.TextDocumentCompletion != nil {
 		capabilities.CompletionProvider = &protocol316.CompletionOptions{}
 	}
@@ -1009,10 +1120,10 @@ func (self *Handler) CreateServerCapabilities() ServerCapabilities {
 		}
 	}
 
-	if self.TextDocumentDiagnostic != nil {
+	if (self.TextDocumentDiagnostic != nil) || (self.WorkspaceDiagnostic != nil) {
 		capabilities.DiagnosticProvider = DiagnosticOptions{
 			InterFileDependencies: true,
-			WorkspaceDiagnostics:  false,
+			WorkspaceDiagnostics:  self.WorkspaceDiagnostic != nil,
 		}
 	}
 
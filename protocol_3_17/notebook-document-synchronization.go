@@ -0,0 +1,497 @@
+package protocol
+
+import (
+	"encoding/json"
+
+	"github.com/tliron/glsp"
+	protocol316 "github.com/tliron/glsp/protocol_3_16"
+)
+
+// ========================================================================================
+// Notebook Document Synchronization
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/#notebookDocument_synchronization
+// ========================================================================================
+
+/**
+ * Notebook specific client capabilities.
+ *
+ * @since 3.17.0
+ */
+type NotebookDocumentSyncClientCapabilities struct {
+	/**
+	 * Whether implementation supports dynamic registration. If this is
+	 * set to `true` the client supports the new
+	 * `(NotebookDocumentSyncRegistrationOptions & NotebookDocumentSyncOptions)`
+	 * return value for the corresponding server capability as well.
+	 */
+	DynamicRegistration *bool `json:"dynamicRegistration,omitempty"`
+
+	/**
+	 * The client supports sending execution summary data per cell.
+	 */
+	ExecutionSummarySupport *bool `json:"executionSummarySupport,omitempty"`
+}
+
+/**
+ * Capabilities specific to the notebook document support.
+ *
+ * @since 3.17.0
+ */
+type NotebookDocumentClientCapabilities struct {
+	/**
+	 * Capabilities specific to notebook document synchronization
+	 *
+	 * @since 3.17.0
+	 */
+	Synchronization NotebookDocumentSyncClientCapabilities `json:"synchronization"`
+}
+
+/**
+ * A notebook cell kind.
+ *
+ * @since 3.17.0
+ */
+type NotebookCellKind protocol316.Integer
+
+const (
+	/**
+	 * A markup-cell is formatted source that is used for display.
+	 */
+	NotebookCellKindMarkup = NotebookCellKind(1)
+
+	/**
+	 * A code-cell is source code.
+	 */
+	NotebookCellKindCode = NotebookCellKind(2)
+)
+
+/**
+ * @since 3.17.0
+ */
+type ExecutionSummary struct {
+	/**
+	 * A strict monotonically increasing value
+	 * indicating the execution order of a cell
+	 * inside a notebook.
+	 */
+	ExecutionOrder protocol316.UInteger `json:"executionOrder"`
+
+	/**
+	 * Whether the execution was successful or
+	 * not if known by the client.
+	 */
+	Success *bool `json:"success,omitempty"`
+}
+
+/**
+ * A notebook cell.
+ *
+ * A cell's document URI must be unique across ALL notebook
+ * cells and can therefore be used to uniquely identify a
+ * notebook cell or the cell's text document.
+ *
+ * @since 3.17.0
+ */
+type NotebookCell struct {
+	/**
+	 * The cell's kind
+	 */
+	Kind NotebookCellKind `json:"kind"`
+
+	/**
+	 * The URI of the cell's text document
+	 * content.
+	 */
+	Document protocol316.DocumentUri `json:"document"`
+
+	/**
+	 * Additional metadata stored with the cell.
+	 */
+	Metadata map[string]any `json:"metadata,omitempty"`
+
+	/**
+	 * Additional execution summary information
+	 * if supported by the client.
+	 */
+	ExecutionSummary *ExecutionSummary `json:"executionSummary,omitempty"`
+}
+
+/**
+ * A notebook document.
+ *
+ * @since 3.17.0
+ */
+type NotebookDocument struct {
+	/**
+	 * The notebook document's URI.
+	 */
+	URI protocol316.URI `json:"uri"`
+
+	/**
+	 * The type of the notebook.
+	 */
+	NotebookType string `json:"notebookType"`
+
+	/**
+	 * The version number of this document (it will increase after each
+	 * change, including undo/redo).
+	 */
+	Version protocol316.Integer `json:"version"`
+
+	/**
+	 * Additional metadata stored with the notebook
+	 * document.
+	 */
+	Metadata map[string]any `json:"metadata,omitempty"`
+
+	/**
+	 * The cells of a notebook.
+	 */
+	Cells []NotebookCell `json:"cells"`
+}
+
+/**
+ * A notebook document filter denotes a notebook document by
+ * different properties. At least one of the properties must be set.
+ *
+ * @since 3.17.0
+ */
+type NotebookDocumentFilter struct {
+	/**
+	 * The type of the enclosing notebook.
+	 */
+	NotebookType *string `json:"notebookType,omitempty"`
+
+	/**
+	 * A Uri [scheme](#Uri.scheme), like `file` or `untitled`.
+	 */
+	Scheme *string `json:"scheme,omitempty"`
+
+	/**
+	 * A glob pattern.
+	 */
+	Pattern *string `json:"pattern,omitempty"`
+}
+
+/**
+ * A notebook cell text document filter denotes a cell text
+ * document by different properties.
+ *
+ * @since 3.17.0
+ */
+type NotebookCellTextDocumentFilter struct {
+	/**
+	 * A filter that matches against the notebook
+	 * containing the notebook cell. If a string
+	 * value is provided it matches against the
+	 * notebook type. '*' matches every notebook.
+	 */
+	Notebook any `json:"notebook"` // string | NotebookDocumentFilter
+
+	/**
+	 * A language id like `python`.
+	 *
+	 * Will be matched against the language id of the
+	 * notebook cell document. '*' matches every language.
+	 */
+	Language *string `json:"language,omitempty"`
+}
+
+/**
+ * Options specific to a notebook plus its cells
+ * to be synced to the server.
+ *
+ * If a selector provides a notebook document
+ * filter but no cell selector all cells of a
+ * matching notebook document will be synced.
+ *
+ * If a selector provides no notebook document
+ * filter but only a cell selector all notebook
+ * documents that contain at least one matching
+ * cell will be synced.
+ *
+ * @since 3.17.0
+ */
+type NotebookDocumentSyncOptions struct {
+	/**
+	 * The notebooks to be synced
+	 */
+	NotebookSelector []NotebookDocumentSyncOptionsSelector `json:"notebookSelector"`
+
+	/**
+	 * Whether save notification should be forwarded to
+	 * the server. Will only be honored if mode === `notebook`.
+	 */
+	Save *bool `json:"save,omitempty"`
+}
+
+type NotebookDocumentSyncOptionsSelector struct {
+	/**
+	 * The notebook to be synced. If a string
+	 * value is provided it matches against the
+	 * notebook type. '*' matches every notebook.
+	 */
+	Notebook any `json:"notebook,omitempty"` // nil | string | NotebookDocumentFilter
+
+	/**
+	 * The cells of the matching notebook to be synced.
+	 */
+	Cells []NotebookDocumentSyncOptionsSelectorCell `json:"cells,omitempty"`
+}
+
+type NotebookDocumentSyncOptionsSelectorCell struct {
+	Language string `json:"language"`
+}
+
+/**
+ * Registration options specific to a notebook.
+ *
+ * @since 3.17.0
+ */
+type NotebookDocumentSyncRegistrationOptions struct {
+	NotebookDocumentSyncOptions
+	protocol316.StaticRegistrationOptions
+}
+
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/#notebookDocument_didOpen
+
+const MethodNotebookDocumentDidOpen = protocol316.Method("notebookDocument/didOpen")
+
+type NotebookDocumentDidOpenFunc func(context *glsp.Context, params *DidOpenNotebookDocumentParams) error
+
+/**
+ * The params sent in an open notebook document notification.
+ *
+ * @since 3.17.0
+ */
+type DidOpenNotebookDocumentParams struct {
+	/**
+	 * The notebook document that got opened.
+	 */
+	NotebookDocument NotebookDocument `json:"notebookDocument"`
+
+	/**
+	 * The text documents that represent the content
+	 * of a notebook cell.
+	 */
+	CellTextDocuments []protocol316.TextDocumentItem `json:"cellTextDocuments"`
+}
+
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/#notebookDocument_didChange
+
+const MethodNotebookDocumentDidChange = protocol316.Method("notebookDocument/didChange")
+
+type NotebookDocumentDidChangeFunc func(context *glsp.Context, params *DidChangeNotebookDocumentParams) error
+
+/**
+ * The params sent in a change notebook document notification.
+ *
+ * @since 3.17.0
+ */
+type DidChangeNotebookDocumentParams struct {
+	/**
+	 * The notebook document that did change. The version number points
+	 * to the version after all provided changes have been applied.
+	 */
+	NotebookDocument VersionedNotebookDocumentIdentifier `json:"notebookDocument"`
+
+	/**
+	 * The actual changes to the notebook document.
+	 *
+	 * The change describes single state change to the notebook document.
+	 * So it moves a notebook document, its cells and its cell text document
+	 * contents from state S to S'.
+	 *
+	 * To mirror the content of a notebook using change events use the
+	 * following approach:
+	 * - start with the same initial content
+	 * - apply the 'notebookDocument/didChange' notifications in the order
+	 *   you receive them.
+	 */
+	Change NotebookDocumentChangeEvent `json:"change"`
+}
+
+/**
+ * A versioned notebook document identifier.
+ *
+ * @since 3.17.0
+ */
+type VersionedNotebookDocumentIdentifier struct {
+	/**
+	 * The version number of this notebook document.
+	 */
+	Version protocol316.Integer `json:"version"`
+
+	/**
+	 * The notebook document's URI.
+	 */
+	URI protocol316.URI `json:"uri"`
+}
+
+/**
+ * A change event for a notebook document.
+ *
+ * @since 3.17.0
+ */
+type NotebookDocumentChangeEvent struct {
+	/**
+	 * The changed meta data if any.
+	 */
+	Metadata map[string]any `json:"metadata,omitempty"`
+
+	/**
+	 * Changes to cells
+	 */
+	Cells *NotebookDocumentChangeEventCells `json:"cells,omitempty"`
+}
+
+type NotebookDocumentChangeEventCells struct {
+	/**
+	 * Changes to the cell structure to add or
+	 * remove cells.
+	 */
+	Structure *NotebookDocumentChangeEventCellsStructure `json:"structure,omitempty"`
+
+	/**
+	 * Changes to notebook cells properties like its
+	 * kind, execution summary or metadata.
+	 */
+	Data []NotebookCell `json:"data,omitempty"`
+
+	/**
+	 * Changes to the text content of notebook cells.
+	 */
+	TextContent []NotebookDocumentChangeEventCellsTextContent `json:"textContent,omitempty"`
+}
+
+type NotebookDocumentChangeEventCellsStructure struct {
+	/**
+	 * The change to the cell array.
+	 */
+	Array NotebookCellArrayChange `json:"array"`
+
+	/**
+	 * Additional opened cell text documents.
+	 */
+	DidOpen []protocol316.TextDocumentItem `json:"didOpen,omitempty"`
+
+	/**
+	 * Additional closed cell text documents.
+	 */
+	DidClose []protocol316.TextDocumentIdentifier `json:"didClose,omitempty"`
+}
+
+type NotebookDocumentChangeEventCellsTextContent struct {
+	Document protocol316.VersionedTextDocumentIdentifier `json:"document"`
+
+	Changes []any `json:"changes"` // TextDocumentContentChangeEvent or TextDocumentContentChangeEventWhole
+}
+
+// ([json.Unmarshaler] interface)
+func (self *NotebookDocumentChangeEventCellsTextContent) UnmarshalJSON(data []byte) error {
+	var value struct {
+		Document protocol316.VersionedTextDocumentIdentifier `json:"document"`
+		Changes  []json.RawMessage                           `json:"changes"` // TextDocumentContentChangeEvent or TextDocumentContentChangeEventWhole
+	}
+
+	if err := json.Unmarshal(data, &value); err == nil {
+		self.Document = value.Document
+
+		for _, change := range value.Changes {
+			var changeEvent protocol316.TextDocumentContentChangeEvent
+			if err = json.Unmarshal(change, &changeEvent); err == nil {
+				if changeEvent.Range != nil {
+					self.Changes = append(self.Changes, changeEvent)
+				} else {
+					changeEventWhole := protocol316.TextDocumentContentChangeEventWhole{
+						Text: changeEvent.Text,
+					}
+					self.Changes = append(self.Changes, changeEventWhole)
+				}
+			} else {
+				return err
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+/**
+ * A change describing how to move a `NotebookCell`
+ * array from state S to S'.
+ *
+ * @since 3.17.0
+ */
+type NotebookCellArrayChange struct {
+	/**
+	 * The start offset of the cell that changed.
+	 */
+	Start protocol316.UInteger `json:"start"`
+
+	/**
+	 * The deleted cells
+	 */
+	DeleteCount protocol316.UInteger `json:"deleteCount"`
+
+	/**
+	 * The new cells, if any
+	 */
+	Cells []NotebookCell `json:"cells,omitempty"`
+}
+
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/#notebookDocument_didSave
+
+const MethodNotebookDocumentDidSave = protocol316.Method("notebookDocument/didSave")
+
+type NotebookDocumentDidSaveFunc func(context *glsp.Context, params *DidSaveNotebookDocumentParams) error
+
+/**
+ * The params sent in a save notebook document notification.
+ *
+ * @since 3.17.0
+ */
+type DidSaveNotebookDocumentParams struct {
+	/**
+	 * The notebook document that got saved.
+	 */
+	NotebookDocument NotebookDocumentIdentifier `json:"notebookDocument"`
+}
+
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/#notebookDocument_didClose
+
+const MethodNotebookDocumentDidClose = protocol316.Method("notebookDocument/didClose")
+
+type NotebookDocumentDidCloseFunc func(context *glsp.Context, params *DidCloseNotebookDocumentParams) error
+
+/**
+ * The params sent in a close notebook document notification.
+ *
+ * @since 3.17.0
+ */
+type DidCloseNotebookDocumentParams struct {
+	/**
+	 * The notebook document that got closed.
+	 */
+	NotebookDocument NotebookDocumentIdentifier `json:"notebookDocument"`
+
+	/**
+	 * The text documents that represent the content
+	 * of a notebook cell that got closed.
+	 */
+	CellTextDocuments []protocol316.TextDocumentIdentifier `json:"cellTextDocuments"`
+}
+
+/**
+ * A literal to identify a notebook document in the client.
+ *
+ * @since 3.17.0
+ */
+type NotebookDocumentIdentifier struct {
+	/**
+	 * The notebook document's URI.
+	 */
+	URI protocol316.URI `json:"uri"`
+}
@@ -0,0 +1,84 @@
+package protocol
+
+import (
+	"slices"
+
+	"github.com/tliron/glsp"
+)
+
+// Set Handler.PositionEncodings to this to opt in to UTF-8 when the client
+// supports it. UTF-8 is a good choice because Go strings are UTF-8, so no
+// conversion is needed. Make sure to convert positions with
+// GetContextPositionEncoding (in protocol_3_16) if you do.
+var UTF8PositionEncodings = []PositionEncodingKind{PositionEncodingKindUTF8, PositionEncodingKindUTF16}
+
+// Returns the first of the preferred encodings that the client supports.
+// According to the spec UTF-16 must always be supported.
+func NegotiatePositionEncoding(clientCapabilities *ClientCapabilities, preferred []PositionEncodingKind) PositionEncodingKind {
+	var supported []PositionEncodingKind
+	if clientCapabilities.General != nil {
+		supported = clientCapabilities.General.PositionEncodings
+	}
+
+	for _, encoding := range preferred {
+		if (encoding == PositionEncodingKindUTF16) || slices.Contains(supported, encoding) {
+			return encoding
+		}
+	}
+
+	return PositionEncodingKindUTF16
+}
+
+// Stores the negotiated encoding in the session so that it is available to
+// the initialize handler func. Negotiation is opt-in: without preferred
+// encodings we stick to UTF-16, the only encoding required by the spec. Without
+// a session we cannot track it, so we also stick to UTF-16.
+func (self *Handler) negotiatePositionEncoding(context *glsp.Context, params *InitializeParams) PositionEncodingKind {
+	if context.Session == nil {
+		return PositionEncodingKindUTF16
+	}
+
+	encoding := PositionEncodingKindUTF16
+	if self.PositionEncodings != nil {
+		encoding = NegotiatePositionEncoding(&params.Capabilities, self.PositionEncodings)
+	}
+	context.Session.SetPositionEncoding(string(encoding))
+	return encoding
+}
+
+// Adds the negotiated encoding to the result unless the initialize handler
+// func has already set it, in which case we respect its choice.
+func (self *Handler) setPositionEncoding(context *glsp.Context, result any, encoding PositionEncodingKind) any {
+	if context.Session == nil {
+		return result
+	}
+
+	var initializeResult *InitializeResult
+	switch result_ := result.(type) {
+	case InitializeResult:
+		initializeResult = &result_
+		result = initializeResult
+
+	case *InitializeResult:
+		initializeResult = result_
+
+	default:
+		// We can't tell the client, so it will assume the default
+		context.Session.SetPositionEncoding(string(PositionEncodingKindUTF16))
+		return result
+	}
+
+	if initializeResult.Capabilities.PositionEncoding == nil {
+		// Support the deprecated location, too
+		initializeResult.Capabilities.PositionEncoding = initializeResult.PositionEncoding
+	}
+
+	if initializeResult.Capabilities.PositionEncoding != nil {
+		context.Session.SetPositionEncoding(string(*initializeResult.Capabilities.PositionEncoding))
+	} else if encoding != PositionEncodingKindUTF16 {
+		initializeResult.Capabilities.PositionEncoding = &encoding
+	}
+
+	initializeResult.PositionEncoding = nil
+	return result
+}
@@ -0,0 +1,153 @@
+package semantictokens
+
+import (
+	"fmt"
+	"slices"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+//
+// Legend
+//
+
+// Maps token types and modifiers to the indexes used in the wire encoding.
+// The same legend must be advertised in the server capabilities, see
+// [Legend.Protocol].
+type Legend struct {
+	types     map[protocol.SemanticTokenType]protocol.UInteger
+	modifiers map[protocol.SemanticTokenModifier]protocol.UInteger
+	legend    protocol.SemanticTokensLegend
+}
+
+// There can be at most 32 modifiers, because they are encoded as bit flags.
+func NewLegend(types []protocol.SemanticTokenType, modifiers []protocol.SemanticTokenModifier) (*Legend, error) {
+	if len(modifiers) > 32 {
+		return nil, fmt.Errorf("too many semantic token modifiers: %d", len(modifiers))
+	}
+
+	self := Legend{
+		types:     make(map[protocol.SemanticTokenType]protocol.UInteger),
+		modifiers: make(map[protocol.SemanticTokenModifier]protocol.UInteger),
+		legend: protocol.SemanticTokensLegend{
+			TokenTypes:     make([]string, len(types)),
+			TokenModifiers: make([]string, len(modifiers)),
+		},
+	}
+
+	for index, type_ := range types {
+		self.types[type_] = protocol.UInteger(index)
+		self.legend.TokenTypes[index] = string(type_)
+	}
+
+	for index, modifier := range modifiers {
+		self.modifiers[modifier] = protocol.UInteger(index)
+		self.legend.TokenModifiers[index] = string(modifier)
+	}
+
+	return &self, nil
+}
+
+// For use in [protocol.SemanticTokensOptions].
+func (self *Legend) Protocol() protocol.SemanticTokensLegend {
+	return self.legend
+}
+
+//
+// Token
+//
+
+// A token in absolute coordinates. Start and Length are in the negotiated
+// position encoding.
+type Token struct {
+	Line      protocol.UInteger
+	Start     protocol.UInteger
+	Length    protocol.UInteger
+	Type      protocol.SemanticTokenType
+	Modifiers []protocol.SemanticTokenModifier
+}
+
+//
+// Builder
+//
+
+// Collects tokens in any order and encodes them in the relative five-integer
+// encoding of the spec.
+type Builder struct {
+	legend *Legend
+	tokens []Token
+}
+
+func NewBuilder(legend *Legend) *Builder {
+	return &Builder{legend: legend}
+}
+
+func (self *Builder) Push(line protocol.UInteger, start protocol.UInteger, length protocol.UInteger, type_ protocol.SemanticTokenType, modifiers ...protocol.SemanticTokenModifier) {
+	self.tokens = append(self.tokens, Token{
+		Line:      line,
+		Start:     start,
+		Length:    length,
+		Type:      type_,
+		Modifiers: modifiers,
+	})
+}
+
+func (self *Builder) PushToken(token Token) {
+	self.tokens = append(self.tokens, token)
+}
+
+// Removes all tokens so that the builder can be reused.
+func (self *Builder) Reset() {
+	self.tokens = self.tokens[:0]
+}
+
+// Sorts the tokens by position and encodes them. Will return an error if a
+// token type or modifier is not in the legend.
+func (self *Builder) Encode() ([]protocol.UInteger, error) {
+	slices.SortStableFunc(self.tokens, func(a Token, b Token) int {
+		if a.Line != b.Line {
+			return int(int64(a.Line) - int64(b.Line))
+		}
+		return int(int64(a.Start) - int64(b.Start))
+	})
+
+	data := make([]protocol.UInteger, 0, len(self.tokens)*5)
+	var line, start protocol.UInteger
+	for _, token := range self.tokens {
+		type_, ok := self.legend.types[token.Type]
+		if !ok {
+			return nil, fmt.Errorf("semantic token type not in legend: %s", token.Type)
+		}
+
+		var modifiers protocol.UInteger
+		for _, modifier := range token.Modifiers {
+			if index, ok := self.legend.modifiers[modifier]; ok {
+				modifiers |= 1 << index
+			} else {
+				return nil, fmt.Errorf("semantic token modifier not in legend: %s", modifier)
+			}
+		}
+
+		deltaLine := token.Line - line
+		deltaStart := token.Start
+		if deltaLine == 0 {
+			deltaStart -= start
+		}
+
+		data = append(data, deltaLine, deltaStart, token.Length, type_, modifiers)
+		line = token.Line
+		start = token.Start
+	}
+
+	return data, nil
+}
+
+// Encodes the tokens into a result without a result ID. See [Cache.Full] for
+// results that support deltas.
+func (self *Builder) Build() (*protocol.SemanticTokens, error) {
+	if data, err := self.Encode(); err == nil {
+		return &protocol.SemanticTokens{Data: data}, nil
+	} else {
+		return nil, err
+	}
+}
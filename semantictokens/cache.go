@@ -0,0 +1,117 @@
+package semantictokens
+
+import (
+	"strconv"
+	"sync"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+//
+// Cache
+//
+
+// A concurrency-safe cache of the most recent result for every document,
+// which is used to compute deltas for "textDocument/semanticTokens/full/delta".
+// Entries should be deleted when documents are closed.
+type Cache struct {
+	results map[protocol.DocumentUri]cacheResult
+	nextID  uint64
+	lock    sync.Mutex
+}
+
+type cacheResult struct {
+	id   string
+	data []protocol.UInteger
+}
+
+func NewCache() *Cache {
+	return &Cache{
+		results: make(map[protocol.DocumentUri]cacheResult),
+	}
+}
+
+// Caches the data as the document's most recent result and returns it with a
+// new result ID. Use it for "textDocument/semanticTokens/full".
+func (self *Cache) Full(uri protocol.DocumentUri, data []protocol.UInteger) *protocol.SemanticTokens {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	id := self.store(uri, data)
+	return &protocol.SemanticTokens{
+		ResultID: &id,
+		Data:     data,
+	}
+}
+
+// Caches the data as the document's most recent result and returns the edits
+// from the previous result. If the previous result is no longer cached then
+// returns the full result instead, which is also allowed by the spec. Use it
+// for "textDocument/semanticTokens/full/delta".
+//
+// Returns: *protocol.SemanticTokensDelta | *protocol.SemanticTokens
+func (self *Cache) Delta(uri protocol.DocumentUri, previousResultID string, data []protocol.UInteger) any {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	previous, ok := self.results[uri]
+	id := self.store(uri, data)
+
+	if ok && (previous.id == previousResultID) {
+		return &protocol.SemanticTokensDelta{
+			ResultId: &id,
+			Edits:    Edits(previous.data, data),
+		}
+	} else {
+		return &protocol.SemanticTokens{
+			ResultID: &id,
+			Data:     data,
+		}
+	}
+}
+
+func (self *Cache) Delete(uri protocol.DocumentUri) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	delete(self.results, uri)
+}
+
+// Must be called while holding the lock
+func (self *Cache) store(uri protocol.DocumentUri, data []protocol.UInteger) string {
+	self.nextID++
+	id := strconv.FormatUint(self.nextID, 10)
+	self.results[uri] = cacheResult{id: id, data: data}
+	return id
+}
+
+// Returns the edits that transform the previous data into the current data.
+// Unchanged data at the start and end is kept, so that there is at most a
+// single edit for the changed data in between, which for the typical case of
+// a localized change to a document is minimal.
+func Edits(previous []protocol.UInteger, current []protocol.UInteger) []protocol.SemanticTokensEdit {
+	prefix := 0
+	for (prefix < len(previous)) && (prefix < len(current)) && (previous[prefix] == current[prefix]) {
+		prefix++
+	}
+
+	suffix := 0
+	for (suffix < len(previous)-prefix) && (suffix < len(current)-prefix) && (previous[len(previous)-1-suffix] == current[len(current)-1-suffix]) {
+		suffix++
+	}
+
+	deleteCount := len(previous) - prefix - suffix
+	insert := current[prefix : len(current)-suffix]
+	if (deleteCount == 0) && (len(insert) == 0) {
+		return []protocol.SemanticTokensEdit{}
+	}
+
+	edit := protocol.SemanticTokensEdit{
+		Start:       protocol.UInteger(prefix),
+		DeleteCount: protocol.UInteger(deleteCount),
+	}
+	if len(insert) > 0 {
+		// Copy so that the edit does not share the cached data
+		edit.Data = append([]protocol.UInteger(nil), insert...)
+	}
+	return []protocol.SemanticTokensEdit{edit}
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	contextpkg "context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//
+// Args
+//
+
+// The command line arguments used by vscode-languageclient to tell a language
+// server how to communicate, plus "--ws=<address>" (e.g.
+// "--ws=127.0.0.1:8080") and "--debug". See [ParseArgs].
+type Args struct {
+	Stdio           bool
+	Socket          string // a port or an address at which the client is listening
+	Pipe            string // a Unix domain socket at which the client is listening
+	NodeIPC         bool
+	WebSocket       string // an address to listen at
+	ClientProcessID int    // 0 if not provided; see Server.WatchClientProcess
+	Debug           bool
+}
+
+// Parses the arguments, excluding the program name (e.g. os.Args[1:]). Values
+// can be provided either as "--socket=5000" or as "--socket 5000". "--socket"
+// (or "--port"), "--pipe", "--ws" and "--clientProcessId" require a value,
+// e.g. a bare "--ws" is an error. Unknown arguments are ignored, because
+// clients may provide their own.
+func ParseArgs(args []string) (*Args, error) {
+	var self Args
+
+	for index := 0; index < len(args); index++ {
+		name, value, hasValue := strings.Cut(args[index], "=")
+
+		// Value for a name that requires one
+		getValue := func() (string, error) {
+			if hasValue {
+				return value, nil
+			} else if (index+1 < len(args)) && !strings.HasPrefix(args[index+1], "--") {
+				index++
+				return args[index], nil
+			} else {
+				return "", fmt.Errorf("missing value for argument: %s", name)
+			}
+		}
+
+		var err error
+		switch name {
+		case "--stdio":
+			self.Stdio = true
+
+		case "--socket", "--port":
+			self.Socket, err = getValue()
+
+		case "--pipe":
+			self.Pipe, err = getValue()
+
+		case "--node-ipc":
+			self.NodeIPC = true
+
+		case "--ws":
+			self.WebSocket, err = getValue()
+
+		case "--clientProcessId":
+			if value, err = getValue(); err == nil {
+				if self.ClientProcessID, err = strconv.Atoi(value); err != nil {
+					err = fmt.Errorf("invalid value for argument %s: %s", name, value)
+				}
+			}
+
+		case "--debug":
+			self.Debug = true
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	transports := 0
+	for _, transport := range []bool{self.Stdio, self.Socket != "", self.Pipe != "", self.NodeIPC, self.WebSocket != ""} {
+		if transport {
+			transports++
+		}
+	}
+	if transports > 1 {
+		return nil, fmt.Errorf("more than one transport in arguments: %s", strings.Join(args, " "))
+	}
+
+	return &self, nil
+}
+
+// Parses the arguments (including the program name, e.g. os.Args) with
+// [ParseArgs] and runs the server with the transport they specify. Defaults
+// to stdio.
+func (self *Server) RunFromArgs(args []string) error {
+	return self.RunFromArgsContext(contextpkg.Background(), args)
+}
+
+// The server is shut down when the context is done.
+func (self *Server) RunFromArgsContext(context contextpkg.Context, args []string) error {
+	if len(args) > 0 {
+		args = args[1:]
+	}
+
+	args_, err := ParseArgs(args)
+	if err != nil {
+		return err
+	}
+
+	return self.RunArgs(context, args_)
+}
+
+// Runs the server with the transport specified by the arguments. Defaults to
+// stdio.
+func (self *Server) RunArgs(context contextpkg.Context, args *Args) error {
+	if args.Debug {
+		self.Debug = true
+	}
+
+	if args.ClientProcessID != 0 {
+		self.watchClientProcess(args.ClientProcessID)
+	}
+
+	switch {
+	case args.Socket != "":
+		address := args.Socket
+		if _, err := strconv.Atoi(address); err == nil {
+			// vscode-languageclient listens on the loopback interface
+			address = "127.0.0.1:" + address
+		}
+		return self.ConnectTCPContext(context, address)
+
+	case args.Pipe != "":
+		return self.ConnectPipeContext(context, args.Pipe)
+
+	case args.NodeIPC:
+		return self.RunNodeJsContext(context)
+
+	case args.WebSocket != "":
+		return self.RunWebSocketContext(context, args.WebSocket)
+
+	default:
+		return self.RunStdioContext(context)
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/tliron/glsp"
+)
+
+// Authenticates a web socket connection before it is upgraded from HTTP. The
+// connection is rejected with HTTP status 401 if an error is returned.
+type AuthenticateWebSocketFunc func(request *http.Request) error
+
+// Authenticates a connection according to the "initializationOptions" in
+// "initialize", which may be nil. The connection is closed if an error is
+// returned.
+type AuthenticateInitializeFunc func(initializationOptions json.RawMessage) error
+
+var errUnauthorized = errors.New("unauthorized")
+
+// Accepts requests with an "Authorization: Bearer <token>" header that has one
+// of the tokens.
+func BearerTokenAuthenticator(tokens ...string) AuthenticateWebSocketFunc {
+	return func(request *http.Request) error {
+		if token, ok := strings.CutPrefix(request.Header.Get("Authorization"), "Bearer "); ok && hasToken(tokens, token) {
+			return nil
+		}
+		return errUnauthorized
+	}
+}
+
+// Accepts "initializationOptions" that have a string property with one of the
+// tokens, e.g. {"token": "..."} for the "token" property.
+func InitializationOptionsTokenAuthenticator(property string, tokens ...string) AuthenticateInitializeFunc {
+	return func(initializationOptions json.RawMessage) error {
+		var options map[string]any
+		if err := json.Unmarshal(initializationOptions, &options); err == nil {
+			if token, ok := options[property].(string); ok && hasToken(tokens, token) {
+				return nil
+			}
+		}
+		return errUnauthorized
+	}
+}
+
+func hasToken(tokens []string, token string) bool {
+	for _, token_ := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(token_)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// Web socket requests without an "Origin" header are not from browsers and
+// are always allowed.
+func (self *Server) checkOrigin(request *http.Request) bool {
+	if len(self.AllowedOrigins) == 0 {
+		return true
+	}
+
+	origin := request.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	if url, err := url.Parse(origin); err == nil {
+		origin = url.Scheme + "://" + url.Host
+	}
+
+	for _, allowedOrigin := range self.AllowedOrigins {
+		if (allowedOrigin == "*") || strings.EqualFold(allowedOrigin, origin) {
+			return true
+		}
+	}
+
+	self.Log.Warningf("web socket origin not allowed: %s", origin)
+	return false
+}
+
+// Called in the order in which messages arrive, before they are scheduled.
+// Returns false if the connection was rejected.
+func (self *dispatcher) authenticateMessage(connection *jsonrpc2.Conn, request *jsonrpc2.Request) bool {
+	if (self.authenticate == nil) || self.authenticated {
+		return true
+	}
+
+	err := errUnauthorized
+	if request.Method == methodInitialize {
+		var params struct {
+			InitializationOptions json.RawMessage `json:"initializationOptions"`
+		}
+		if request.Params != nil {
+			if err_ := json.Unmarshal(*request.Params, &params); err_ != nil {
+				params.InitializationOptions = nil
+			}
+		}
+
+		if err = self.authenticate(params.InitializationOptions); err == nil {
+			self.authenticated = true
+			return true
+		}
+	}
+
+	self.server.Log.Warningf("rejecting connection: %s", err.Error())
+	if !request.Notif {
+		self.reject(connection, request, glsp.CodeInvalidRequest, "authentication failed: "+err.Error())
+	}
+	if err := connection.Close(); (err != nil) && (err != jsonrpc2.ErrClosed) {
+		self.server.Log.Errorf("connection.Close: %s", err.Error())
+	}
+	return false
+}
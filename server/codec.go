@@ -0,0 +1,75 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/tliron/commonlog"
+)
+
+//
+// limitedObjectCodec
+//
+
+// Like [jsonrpc2.VSCodeObjectCodec] but returns an error for messages larger
+// than the maximum size without reading them, which will close the
+// connection. Header lines are limited to the size of the reader's buffer.
+type limitedObjectCodec struct {
+	jsonrpc2.VSCodeObjectCodec
+
+	maxSize int64
+	log     commonlog.Logger
+}
+
+// ([jsonrpc2.ObjectCodec] interface)
+func (self limitedObjectCodec) ReadObject(stream *bufio.Reader, v any) error {
+	var contentLength int64
+	for {
+		line, err := stream.ReadSlice('\r')
+		if err != nil {
+			if err == bufio.ErrBufferFull {
+				err = fmt.Errorf("jsonrpc2: header line too long")
+			}
+			return err
+		}
+		b, err := stream.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b != '\n' {
+			return fmt.Errorf(`jsonrpc2: line endings must be \r\n`)
+		}
+		if (len(line) == 1) && (line[0] == '\r') {
+			break
+		}
+		if value, ok := strings.CutPrefix(string(line), "Content-Length: "); ok {
+			if contentLength, err = strconv.ParseInt(strings.TrimSpace(value), 10, 64); err != nil {
+				return err
+			}
+		}
+	}
+
+	if contentLength <= 0 {
+		return fmt.Errorf("jsonrpc2: no Content-Length header found")
+	}
+
+	if contentLength > self.maxSize {
+		self.log.Warningf("closing connection: message size %d is larger than the maximum %d", contentLength, self.maxSize)
+		return fmt.Errorf("jsonrpc2: message size %d is larger than the maximum %d", contentLength, self.maxSize)
+	}
+
+	return json.NewDecoder(io.LimitReader(stream, contentLength)).Decode(v)
+}
+
+func (self *Server) newObjectCodec() jsonrpc2.ObjectCodec {
+	if self.MaxMessageSize > 0 {
+		return limitedObjectCodec{maxSize: self.MaxMessageSize, log: self.Log}
+	} else {
+		return jsonrpc2.VSCodeObjectCodec{}
+	}
+}
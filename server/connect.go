@@ -0,0 +1,54 @@
+package server
+
+import (
+	contextpkg "context"
+	"net"
+
+	"github.com/tliron/commonlog"
+)
+
+// Instead of listening, dials a Unix domain socket created by the client and
+// serves the single connection, as is done by VS Code's "--pipe" transport.
+// Blocks until the connection is closed.
+//
+// Note that on Windows VS Code uses named pipes, which are not supported.
+func (self *Server) ConnectPipe(path string) error {
+	return self.ConnectPipeContext(contextpkg.Background(), path)
+}
+
+// The server is shut down when the context is done.
+func (self *Server) ConnectPipeContext(context contextpkg.Context, path string) error {
+	return self.connect(context, "unix", path)
+}
+
+// Instead of listening, dials a TCP address at which the client is listening
+// and serves the single connection, as is done by VS Code's "--socket"
+// transport. Blocks until the connection is closed.
+func (self *Server) ConnectTCP(address string) error {
+	return self.ConnectTCPContext(contextpkg.Background(), address)
+}
+
+// The server is shut down when the context is done.
+func (self *Server) ConnectTCPContext(context contextpkg.Context, address string) error {
+	return self.connect(context, "tcp", address)
+}
+
+func (self *Server) connect(context contextpkg.Context, network string, address string) error {
+	if self.isClosed() {
+		return ErrServerClosed
+	}
+
+	var dialer net.Dialer
+	connection, err := dialer.DialContext(context, network, address)
+	if err != nil {
+		self.Log.Criticalf("could not connect to address %s: %v", address, err)
+		return err
+	}
+
+	defer self.shutdownWhenDone(context)()
+
+	log := commonlog.NewKeyValueLogger(self.Log, "address", address)
+	log.Notice("connected")
+	self.ServeStream(connection, log)
+	return nil
+}
@@ -10,24 +10,29 @@ import (
 	"github.com/tliron/commonlog"
 )
 
-func (self *Server) newStreamConnection(stream io.ReadWriteCloser) *jsonrpc2.Conn {
-	handler := self.newHandler()
+func (self *Server) newStreamConnection(stream io.ReadWriteCloser, accepted bool) (*jsonrpc2.Conn, *dispatcher) {
+	handler := self.newHandler(accepted)
+	handler.connection.RemoteAddress = remoteAddress(stream)
 	connectionOptions := self.newConnectionOptions()
 
 	context, cancel := contextpkg.WithTimeout(contextpkg.Background(), self.StreamTimeout)
 	defer cancel()
 
-	return jsonrpc2.NewConn(context, jsonrpc2.NewBufferedStream(stream, jsonrpc2.VSCodeObjectCodec{}), handler, connectionOptions...)
+	return jsonrpc2.NewConn(context, jsonrpc2.NewBufferedStream(stream, self.newObjectCodec()), handler, connectionOptions...), handler
 }
 
-func (self *Server) newWebSocketConnection(socket *websocket.Conn) *jsonrpc2.Conn {
-	handler := self.newHandler()
+func (self *Server) newWebSocketConnection(socket *websocket.Conn, accepted bool) (*jsonrpc2.Conn, *dispatcher) {
+	handler := self.newHandler(accepted)
+	handler.connection.RemoteAddress = remoteAddress(socket)
+	if self.MaxMessageSize > 0 {
+		socket.SetReadLimit(self.MaxMessageSize)
+	}
 	connectionOptions := self.newConnectionOptions()
 
 	context, cancel := contextpkg.WithTimeout(contextpkg.Background(), self.WebSocketTimeout)
 	defer cancel()
 
-	return jsonrpc2.NewConn(context, wsjsonrpc2.NewObjectStream(socket), handler, connectionOptions...)
+	return jsonrpc2.NewConn(context, wsjsonrpc2.NewObjectStream(socket), handler, connectionOptions...), handler
 }
 
 func (self *Server) newConnectionOptions() []jsonrpc2.ConnOpt {
@@ -0,0 +1,485 @@
+package server
+
+import (
+	contextpkg "context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/tliron/glsp"
+)
+
+const (
+	methodInitialize                   = "initialize"
+	methodInitialized                  = "initialized"
+	methodShutdown                     = "shutdown"
+	methodExit                         = "exit"
+	methodCancelRequest                = "$/cancelRequest"
+	methodProgress                     = "$/progress"
+	methodWindowWorkDoneProgressCancel = "window/workDoneProgress/cancel"
+	methodWindowShowMessage            = "window/showMessage"
+)
+
+var errRequestCancelled = errors.New("request cancelled")
+
+//
+// DispatchMode
+//
+
+type DispatchMode int
+
+const (
+	// Messages are handled one at a time in the order in which they arrive
+	DispatchSerial DispatchMode = iota
+
+	// Requests are handled concurrently, while notifications are handled one
+	// at a time in the order in which they arrive. Notifications about a text
+	// document also act as barriers for requests about that same document:
+	// they wait for earlier requests to finish and later requests wait for
+	// them. Other notifications and lifecycle messages act as barriers for all
+	// messages. Requests in Server.BarrierMethods act as barriers, too.
+	// "$/cancelRequest" and progress notifications are handled immediately.
+	DispatchConcurrent
+)
+
+//
+// dispatcher
+//
+
+// A per-connection [jsonrpc2.Handler]. It schedules messages according to the
+// server's [DispatchMode] while continuing to read from the connection, which
+// allows it to cancel in-flight requests when "$/cancelRequest" arrives.
+type dispatcher struct {
+	server     *Server
+	handler    glsp.Handler
+	session    *glsp.Session
+	connection *Connection
+
+	context  contextpkg.Context // cancelled on disconnect
+	cancel   contextpkg.CancelFunc
+	requests map[jsonrpc2.ID]contextpkg.CancelCauseFunc
+
+	globalBarrier    *dispatchJob
+	sinceGlobal      map[*dispatchJob]struct{}
+	documentBarrier  map[string]*dispatchJob
+	sinceDocument    map[string]map[*dispatchJob]struct{}
+	lastNotification *dispatchJob // every notification waits for the previous one
+
+	accepted      bool                       // by one of our listeners
+	authenticate  AuthenticateInitializeFunc // can be nil
+	authenticated bool                       // only accessed by Handle
+
+	draining         bool
+	inFlight         sync.WaitGroup
+	pending          atomic.Int64 // messages
+	queuedRequests   atomic.Int64
+	lastActivity     atomic.Int64 // Unix nanoseconds
+	shutdownReceived atomic.Bool
+
+	start sync.Once
+	lock  sync.Mutex
+}
+
+func (self *Server) newDispatcher(accepted bool) *dispatcher {
+	context, cancel := contextpkg.WithCancel(contextpkg.Background())
+	session := glsp.NewSession()
+	dispatcher := dispatcher{
+		server:          self,
+		handler:         glsp.Chain(self.Handler, self.Middlewares...),
+		accepted:        accepted,
+		session:         session,
+		connection:      self.newConnection(session),
+		context:         context,
+		cancel:          cancel,
+		requests:        make(map[jsonrpc2.ID]contextpkg.CancelCauseFunc),
+		sinceGlobal:     make(map[*dispatchJob]struct{}),
+		documentBarrier: make(map[string]*dispatchJob),
+		sinceDocument:   make(map[string]map[*dispatchJob]struct{}),
+	}
+	if accepted {
+		dispatcher.authenticate = self.AuthenticateInitialize
+	}
+	dispatcher.lastActivity.Store(time.Now().UnixNano())
+	return &dispatcher
+}
+
+// ([jsonrpc2.Handler] interface)
+func (self *dispatcher) Handle(context contextpkg.Context, connection *jsonrpc2.Conn, request *jsonrpc2.Request) {
+	self.start.Do(func() {
+		self.connection.connection.Store(connection)
+		go func() {
+			<-connection.DisconnectNotify()
+			self.cancel()
+		}()
+	})
+
+	self.lastActivity.Store(time.Now().UnixNano())
+
+	if !self.authenticateMessage(connection, request) {
+		return
+	}
+
+	if request.Method == methodCancelRequest {
+		// Cancel immediately, but still let the handler know about it
+		self.cancelRequest(request)
+	}
+
+	self.lock.Lock()
+	if self.draining {
+		self.lock.Unlock()
+		if !request.Notif {
+			self.reject(connection, request, glsp.CodeInvalidRequest, "server is shutting down")
+		}
+		return
+	}
+	if !request.Notif && (self.server.MaxQueuedRequests > 0) && (self.queuedRequests.Load() >= int64(self.server.MaxQueuedRequests)) {
+		self.lock.Unlock()
+		self.reject(connection, request, glsp.CodeRequestFailed, "too many queued requests")
+		return
+	}
+	self.inFlight.Add(1)
+	self.pending.Add(1)
+	if !request.Notif {
+		self.queuedRequests.Add(1)
+	}
+	self.lock.Unlock()
+
+	requestContext, cancel := contextpkg.WithCancelCause(self.context)
+	if !request.Notif {
+		self.lock.Lock()
+		self.requests[request.ID] = cancel
+		self.lock.Unlock()
+	}
+
+	job := self.schedule(request)
+	go func() {
+		defer self.inFlight.Done()
+		defer self.pending.Add(-1)
+		if !request.Notif {
+			defer self.queuedRequests.Add(-1)
+		}
+		defer cancel(nil)
+		defer self.done(job)
+
+		for _, dependency := range job.dependencies {
+			select {
+			case <-dependency.done:
+			case <-self.context.Done():
+				return
+			}
+		}
+
+		if job.pooled {
+			if !self.server.acquireRequestSlot(self.context) {
+				return
+			}
+			defer self.server.releaseRequestSlot()
+		}
+
+		self.dispatch(requestContext, connection, request)
+	}()
+}
+
+func (self *dispatcher) dispatch(context contextpkg.Context, connection *jsonrpc2.Conn, request *jsonrpc2.Request) {
+	var result any
+	var err error
+	if contextpkg.Cause(context) == errRequestCancelled {
+		// Cancelled while waiting to be handled
+		err = errRequestCancelled
+	} else {
+		result, err = self.server.handle(context, connection, request, self.handler, self.session)
+	}
+
+	switch request.Method {
+	case methodInitialize:
+		if err == nil {
+			self.connection.setClientInfo(request)
+			self.server.watchClientProcess(requestProcessID(request))
+		}
+
+	case methodShutdown:
+		self.shutdownReceived.Store(true)
+
+	case methodExit:
+		if self.server.ExitProcess {
+			go self.server.exit(self.shutdownReceived.Load())
+		}
+	}
+
+	if request.Notif {
+		if err != nil {
+			self.server.Log.Errorf("notification %q handling error: %s", request.Method, err.Error())
+		}
+		return
+	}
+
+	self.lock.Lock()
+	delete(self.requests, request.ID)
+	self.lock.Unlock()
+
+	if contextpkg.Cause(context) == errRequestCancelled {
+		err = &jsonrpc2.Error{
+			Code:    glsp.CodeRequestCancelled,
+			Message: errRequestCancelled.Error(),
+		}
+	}
+
+	response := jsonrpc2.Response{ID: request.ID}
+	if err == nil {
+		err = response.SetResult(result)
+	}
+	if err != nil {
+		if err_, ok := err.(*jsonrpc2.Error); ok {
+			response.Error = err_
+		} else {
+			response.Error = &jsonrpc2.Error{Message: err.Error()}
+		}
+	}
+
+	if err := connection.SendResponse(self.context, &response); err != nil {
+		if err != jsonrpc2.ErrClosed {
+			self.server.Log.Errorf("could not send response to %q: %s", request.Method, err.Error())
+		}
+	}
+}
+
+func (self *dispatcher) reject(connection *jsonrpc2.Conn, request *jsonrpc2.Request, code int64, message string) {
+	if err := connection.ReplyWithError(self.context, request.ID, &jsonrpc2.Error{Code: code, Message: message}); err != nil {
+		if err != jsonrpc2.ErrClosed {
+			self.server.Log.Errorf("could not send response to %q: %s", request.Method, err.Error())
+		}
+	}
+}
+
+// Closes the connection if no messages were received for the duration and
+// none are pending
+func (self *dispatcher) closeWhenIdle(connection *jsonrpc2.Conn, timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			idle := time.Since(time.Unix(0, self.lastActivity.Load()))
+			if (idle >= timeout) && (self.pending.Load() == 0) {
+				self.server.Log.Infof("closing connection %d after being idle for %s", self.connection.ID, idle.Round(time.Second))
+				if err := connection.Close(); (err != nil) && (err != jsonrpc2.ErrClosed) {
+					self.server.Log.Errorf("connection.Close: %s", err.Error())
+				}
+				return
+			}
+			timer.Reset(max(timeout-idle, time.Second))
+
+		case <-connection.DisconnectNotify():
+			return
+		}
+	}
+}
+
+// New requests will be rejected
+func (self *dispatcher) drain() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.draining = true
+}
+
+// Waits for in-flight requests to finish
+func (self *dispatcher) wait(context contextpkg.Context) error {
+	done := make(chan struct{})
+	go func() {
+		self.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-context.Done():
+		return context.Err()
+	}
+}
+
+func (self *dispatcher) cancelRequest(request *jsonrpc2.Request) {
+	if request.Params == nil {
+		return
+	}
+
+	var params struct {
+		ID jsonrpc2.ID `json:"id"`
+	}
+	if err := json.Unmarshal(*request.Params, &params); err != nil {
+		return
+	}
+
+	self.lock.Lock()
+	cancel, ok := self.requests[params.ID]
+	self.lock.Unlock()
+
+	if ok {
+		cancel(errRequestCancelled)
+	}
+}
+
+//
+// dispatchJob
+//
+
+type dispatchJob struct {
+	document     string // empty for global jobs
+	barrier      bool
+	pooled       bool
+	dependencies []*dispatchJob
+	done         chan struct{}
+}
+
+// Must be called in the order in which messages arrive
+func (self *dispatcher) schedule(request *jsonrpc2.Request) *dispatchJob {
+	job := &dispatchJob{done: make(chan struct{})}
+	ordered := request.Notif
+
+	switch {
+	case self.server.Dispatch != DispatchConcurrent:
+		job.barrier = true
+
+	case (request.Method == methodCancelRequest) || (request.Method == methodProgress) || (request.Method == methodWindowWorkDoneProgressCancel):
+		// These must not wait for the requests they refer to
+		ordered = false
+
+	case (request.Method == methodInitialize) || (request.Method == methodInitialized) || (request.Method == methodShutdown) || (request.Method == methodExit):
+		job.barrier = true
+
+	default:
+		job.document = requestDocument(request)
+		job.barrier = request.Notif || self.server.isBarrierMethod(request.Method)
+		job.pooled = !request.Notif
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.globalBarrier != nil {
+		job.dependencies = append(job.dependencies, self.globalBarrier)
+	}
+
+	if ordered {
+		// Notifications about different documents must not overtake each other
+		if self.lastNotification != nil {
+			job.dependencies = append(job.dependencies, self.lastNotification)
+		}
+		self.lastNotification = job
+	}
+
+	if job.document == "" {
+		if job.barrier {
+			// Wait for everything since the previous global barrier
+			for job_ := range self.sinceGlobal {
+				job.dependencies = append(job.dependencies, job_)
+			}
+			self.globalBarrier = job
+			self.sinceGlobal = make(map[*dispatchJob]struct{})
+			self.documentBarrier = make(map[string]*dispatchJob)
+			self.sinceDocument = make(map[string]map[*dispatchJob]struct{})
+			return job
+		}
+	} else {
+		if barrier, ok := self.documentBarrier[job.document]; ok {
+			job.dependencies = append(job.dependencies, barrier)
+		}
+
+		since, ok := self.sinceDocument[job.document]
+		if !ok {
+			since = make(map[*dispatchJob]struct{})
+			self.sinceDocument[job.document] = since
+		}
+
+		if job.barrier {
+			// Wait for everything about this document since its previous barrier
+			for job_ := range since {
+				job.dependencies = append(job.dependencies, job_)
+			}
+			self.documentBarrier[job.document] = job
+			clear(since)
+		} else {
+			since[job] = struct{}{}
+		}
+	}
+
+	self.sinceGlobal[job] = struct{}{}
+	return job
+}
+
+func (self *Server) isBarrierMethod(method string) bool {
+	if self.BarrierMethods != nil {
+		return slices.Contains(self.BarrierMethods, method)
+	} else {
+		return slices.Contains(DefaultBarrierMethods, method)
+	}
+}
+
+func (self *dispatcher) done(job *dispatchJob) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	close(job.done)
+
+	if self.globalBarrier == job {
+		self.globalBarrier = nil
+	}
+
+	if self.lastNotification == job {
+		self.lastNotification = nil
+	}
+
+	delete(self.sinceGlobal, job)
+
+	if job.document != "" {
+		if self.documentBarrier[job.document] == job {
+			delete(self.documentBarrier, job.document)
+		}
+
+		if since, ok := self.sinceDocument[job.document]; ok {
+			delete(since, job)
+			if (len(since) == 0) && (self.documentBarrier[job.document] == nil) {
+				delete(self.sinceDocument, job.document)
+			}
+		}
+	}
+}
+
+// Returns the URI of the text document the message is about, if any
+func requestDocument(request *jsonrpc2.Request) string {
+	if request.Params == nil {
+		return ""
+	}
+
+	var params struct {
+		TextDocument *struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+	}
+	if err := json.Unmarshal(*request.Params, &params); (err == nil) && (params.TextDocument != nil) {
+		return params.TextDocument.URI
+	} else {
+		return ""
+	}
+}
+
+// Returns the client process ID in "initialize", or 0 if there is none
+func requestProcessID(request *jsonrpc2.Request) int {
+	if request.Params == nil {
+		return 0
+	}
+
+	var params struct {
+		ProcessID *int `json:"processId"`
+	}
+	if err := json.Unmarshal(*request.Params, &params); (err == nil) && (params.ProcessID != nil) {
+		return *params.ProcessID
+	} else {
+		return 0
+	}
+}
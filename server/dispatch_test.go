@@ -0,0 +1,228 @@
+package server
+
+import (
+	contextpkg "context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/tliron/glsp"
+)
+
+func TestDispatchConcurrentNotificationWaitsForRequest(t *testing.T) {
+	release := make(chan struct{})
+	events := make(chan string, 10)
+	server := newTestServer(func(context *glsp.Context) (any, bool, bool, error) {
+		switch context.Method {
+		case "textDocument/hover":
+			events <- "hover started"
+			<-release
+			events <- "hover finished"
+			return nil, true, true, nil
+
+		case "textDocument/didChange":
+			events <- "didChange"
+			return nil, true, true, nil
+		}
+		return nil, false, false, nil
+	})
+
+	connection := newTestConnection(t, server)
+	context := contextpkg.Background()
+	params := textDocumentParams("file:///a.txt")
+
+	waiter, err := connection.DispatchCall(context, "textDocument/hover", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, events, "hover started")
+
+	if err := connection.Notify(context, "textDocument/didChange", params); err != nil {
+		t.Fatal(err)
+	}
+	expectNoEvent(t, events)
+
+	close(release)
+	expectEvent(t, events, "hover finished")
+	expectEvent(t, events, "didChange")
+
+	if err := waiter.Wait(context, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDispatchConcurrentNotificationsKeepOrder(t *testing.T) {
+	release := make(chan struct{})
+	events := make(chan string, 10)
+	server := newTestServer(func(context *glsp.Context) (any, bool, bool, error) {
+		switch context.Method {
+		case "textDocument/hover":
+			events <- "hover started"
+			<-release
+			events <- "hover finished"
+			return nil, true, true, nil
+
+		case "textDocument/didChange":
+			var params struct {
+				TextDocument struct {
+					URI string `json:"uri"`
+				} `json:"textDocument"`
+			}
+			if err := json.Unmarshal(context.Params, &params); err != nil {
+				return nil, true, false, err
+			}
+			events <- "didChange " + params.TextDocument.URI
+			return nil, true, true, nil
+		}
+		return nil, false, false, nil
+	})
+
+	connection := newTestConnection(t, server)
+	context := contextpkg.Background()
+
+	waiter, err := connection.DispatchCall(context, "textDocument/hover", textDocumentParams("file:///a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, events, "hover started")
+
+	for _, uri := range []string{"file:///a.txt", "file:///b.txt"} {
+		if err := connection.Notify(context, "textDocument/didChange", textDocumentParams(uri)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectNoEvent(t, events)
+
+	close(release)
+	expectEvent(t, events, "hover finished")
+	expectEvent(t, events, "didChange file:///a.txt")
+	expectEvent(t, events, "didChange file:///b.txt")
+
+	if err := waiter.Wait(context, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDispatchConcurrentBarrierMethodWaitsForRequest(t *testing.T) {
+	release := make(chan struct{})
+	events := make(chan string, 10)
+	server := newTestServer(func(context *glsp.Context) (any, bool, bool, error) {
+		switch context.Method {
+		case "textDocument/hover":
+			events <- "hover started"
+			<-release
+			events <- "hover finished"
+			return nil, true, true, nil
+
+		case "workspace/executeCommand":
+			events <- "executeCommand"
+			return nil, true, true, nil
+		}
+		return nil, false, false, nil
+	})
+
+	connection := newTestConnection(t, server)
+	context := contextpkg.Background()
+
+	hover, err := connection.DispatchCall(context, "textDocument/hover", textDocumentParams("file:///a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, events, "hover started")
+
+	executeCommand, err := connection.DispatchCall(context, "workspace/executeCommand", map[string]any{"command": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectNoEvent(t, events)
+
+	close(release)
+	expectEvent(t, events, "hover finished")
+	expectEvent(t, events, "executeCommand")
+
+	for _, waiter := range []jsonrpc2.Waiter{hover, executeCommand} {
+		if err := waiter.Wait(context, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDispatchCancelRequest(t *testing.T) {
+	events := make(chan string, 10)
+	server := newTestServer(func(context *glsp.Context) (any, bool, bool, error) {
+		if context.Method == "textDocument/hover" {
+			events <- "hover started"
+			<-context.Context.Done()
+			return nil, true, true, context.Context.Err()
+		}
+		return nil, false, false, nil
+	})
+
+	connection := newTestConnection(t, server)
+	context := contextpkg.Background()
+
+	waiter, err := connection.DispatchCall(context, "textDocument/hover", textDocumentParams("file:///a.txt"), jsonrpc2.PickID(jsonrpc2.ID{Num: 7}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, events, "hover started")
+
+	if err := connection.Notify(context, "$/cancelRequest", map[string]any{"id": 7}); err != nil {
+		t.Fatal(err)
+	}
+
+	var err_ *jsonrpc2.Error
+	if err := waiter.Wait(context, nil); !errors.As(err, &err_) {
+		t.Fatalf("expected a JSON-RPC error, got: %v", err)
+	}
+	if err_.Code != glsp.CodeRequestCancelled {
+		t.Errorf("expected code %d, got %d: %s", glsp.CodeRequestCancelled, err_.Code, err_.Message)
+	}
+}
+
+func newTestServer(handle glsp.HandlerFunc) *Server {
+	server := NewServer(handle, "glsp.test", false)
+	server.Dispatch = DispatchConcurrent
+	return server
+}
+
+func newTestConnection(t *testing.T, server *Server) *jsonrpc2.Conn {
+	serverStream, clientStream := net.Pipe()
+	go server.ServeStream(serverStream, nil)
+
+	connection := jsonrpc2.NewConn(contextpkg.Background(), jsonrpc2.NewBufferedStream(clientStream, jsonrpc2.VSCodeObjectCodec{}), jsonrpc2.HandlerWithError(func(contextpkg.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (any, error) {
+		return nil, nil
+	}))
+	t.Cleanup(func() {
+		connection.Close()
+	})
+	return connection
+}
+
+func textDocumentParams(uri string) map[string]any {
+	return map[string]any{"textDocument": map[string]any{"uri": uri}}
+}
+
+func expectEvent(t *testing.T, events chan string, expected string) {
+	t.Helper()
+	select {
+	case event := <-events:
+		if event != expected {
+			t.Fatalf("expected %q, got %q", expected, event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %q", expected)
+	}
+}
+
+func expectNoEvent(t *testing.T, events chan string) {
+	t.Helper()
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected %q", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
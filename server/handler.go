@@ -2,7 +2,10 @@ package server
 
 import (
 	contextpkg "context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"runtime/debug"
 
 	"github.com/sourcegraph/jsonrpc2"
 	"github.com/tliron/glsp"
@@ -10,11 +13,12 @@ import (
 
 // See: https://github.com/sourcegraph/go-langserver/blob/master/langserver/handler.go#L206
 
-func (self *Server) newHandler() jsonrpc2.Handler {
-	return jsonrpc2.HandlerWithError(self.handle)
+func (self *Server) newHandler(accepted bool) *dispatcher {
+	// Every connection gets its own dispatcher and session
+	return self.newDispatcher(accepted)
 }
 
-func (self *Server) handle(context contextpkg.Context, connection *jsonrpc2.Conn, request *jsonrpc2.Request) (any, error) {
+func (self *Server) handle(context contextpkg.Context, connection *jsonrpc2.Conn, request *jsonrpc2.Request, handler glsp.Handler, session *glsp.Session) (result any, err error) {
 	glspContext := glsp.Context{
 		Method: request.Method,
 		Notify: func(method string, params any) {
@@ -22,29 +26,47 @@ func (self *Server) handle(context contextpkg.Context, connection *jsonrpc2.Conn
 				self.Log.Error(err.Error())
 			}
 		},
-		Call: func(method string, params any, result any) {
-			if err := connection.Call(context, method, params, result); err != nil {
-				self.Log.Error(err.Error())
+		Call: func(context_ contextpkg.Context, method string, params any, result any) error {
+			if context_ == nil {
+				context_ = context
 			}
+			return call(context_, connection, method, params, result)
 		},
 		Context: context,
+		Session: session,
 	}
 
 	if request.Params != nil {
 		glspContext.Params = *request.Params
 	}
 
+	// A panic in one handler should not bring down the whole server
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err_ := newPanicError(request.Method, recovered)
+			self.Log.Criticalf("%s\n%s", err_.Message, debug.Stack())
+			if self.ShowPanicMessages {
+				glspContext.Notify(methodWindowShowMessage, showMessageParams{
+					Type:    messageTypeError,
+					Message: err_.Message,
+				})
+			}
+			result = nil
+			err = newJSONRPCError(err_)
+		}
+	}()
+
 	switch request.Method {
 	case "exit":
 		// We're giving the attached handler a chance to handle it first, but we'll ignore any result
-		self.Handler.Handle(&glspContext)
+		handler.Handle(&glspContext)
 		err := connection.Close()
 		return nil, err
 
 	default:
 		// Note: jsonrpc2 will not even call this function if reqest.Params is invalid JSON,
 		// so we don't need to handle jsonrpc2.CodeParseError here
-		result, validMethod, validParams, err := self.Handler.Handle(&glspContext)
+		result, validMethod, validParams, err := handler.Handle(&glspContext)
 		if !validMethod {
 			return nil, &jsonrpc2.Error{
 				Code:    jsonrpc2.CodeMethodNotFound,
@@ -62,12 +84,58 @@ func (self *Server) handle(context contextpkg.Context, connection *jsonrpc2.Conn
 				}
 			}
 		} else if err != nil {
-			return nil, &jsonrpc2.Error{
-				Code:    jsonrpc2.CodeInvalidRequest,
-				Message: err.Error(),
+			var err_ *glsp.Error
+			if errors.As(err, &err_) {
+				return nil, newJSONRPCError(err_)
+			} else {
+				return nil, &jsonrpc2.Error{
+					Code:    glsp.CodeRequestFailed,
+					Message: err.Error(),
+				}
 			}
 		} else {
 			return result, nil
 		}
 	}
 }
+
+func newError(err *jsonrpc2.Error) *glsp.Error {
+	err_ := glsp.Error{
+		Code:    err.Code,
+		Message: err.Message,
+	}
+	if err.Data != nil {
+		err_.Data = *err.Data
+	}
+	return &err_
+}
+
+func newJSONRPCError(err *glsp.Error) *jsonrpc2.Error {
+	err_ := jsonrpc2.Error{
+		Code:    err.Code,
+		Message: err.Message,
+	}
+	if err.Data != nil {
+		if data, err__ := json.Marshal(err.Data); err__ == nil {
+			data_ := json.RawMessage(data)
+			err_.Data = &data_
+		}
+	}
+	return &err_
+}
+
+// See: protocol_3_16.ShowMessageParams
+type showMessageParams struct {
+	Type    int    `json:"type"`
+	Message string `json:"message"`
+}
+
+// See: protocol_3_16.MessageTypeError
+const messageTypeError = 1
+
+func newPanicError(method string, recovered any) *glsp.Error {
+	return &glsp.Error{
+		Code:    glsp.CodeInternalError,
+		Message: fmt.Sprintf("panic while handling %q: %v", method, recovered),
+	}
+}
@@ -2,8 +2,10 @@ package server
 
 import (
 	"crypto/tls"
+	"errors"
 	"net"
-	"os"
+
+	"github.com/tliron/commonlog"
 )
 
 func (self *Server) newNetworkListener(network string, address string) (*net.Listener, error) {
@@ -13,17 +15,24 @@ func (self *Server) newNetworkListener(network string, address string) (*net.Lis
 		return nil, err
 	}
 
-	cert := os.Getenv("TLS_CERT")
-	key := os.Getenv("TLS_KEY")
-	if (cert != "") && (key != "") {
-		cert, err := tls.X509KeyPair([]byte(cert), []byte(key))
+	if network == "tcp" {
+		config, err := self.newTLSConfig()
 		if err != nil {
+			listener.Close()
 			return nil, err
 		}
-		listener = tls.NewListener(listener, &tls.Config{
-			Certificates: []tls.Certificate{cert},
-		})
+		if config != nil {
+			listener = tls.NewListener(listener, config)
+		}
 	}
 
 	return &listener, nil
 }
+
+// The listener may have already been closed by [Server.Shutdown]
+func (self *Server) closeListener(listener net.Listener, log commonlog.Logger) {
+	self.untrackCloser(listener)
+	if err := listener.Close(); (err != nil) && !errors.Is(err, net.ErrClosed) {
+		log.Errorf("listener.Close: %s", err.Error())
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/tliron/commonlog"
+	"github.com/tliron/glsp"
+)
+
+// Logs how long it took to handle every message at debug level. Put it first
+// in order to include the time spent in the other middlewares.
+func LogLatencyMiddleware(log commonlog.Logger) glsp.Middleware {
+	return func(next glsp.Handler) glsp.Handler {
+		return glsp.HandlerFunc(func(context *glsp.Context) (any, bool, bool, error) {
+			start := time.Now()
+			result, validMethod, validParams, err := next.Handle(context)
+			if err != nil {
+				log.Debugf("handled %q in %s with error: %s", context.Method, time.Since(start), err.Error())
+			} else {
+				log.Debugf("handled %q in %s", context.Method, time.Since(start))
+			}
+			return result, validMethod, validParams, err
+		})
+	}
+}
+
+// Recovers from panics in the next handler by logging the stack and returning
+// an internal error to the client. The server always recovers from panics, so
+// this is only useful for recovering before the outer middlewares.
+func RecoverMiddleware(log commonlog.Logger) glsp.Middleware {
+	return func(next glsp.Handler) glsp.Handler {
+		return glsp.HandlerFunc(func(context *glsp.Context) (result any, validMethod bool, validParams bool, err error) {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					err_ := newPanicError(context.Method, recovered)
+					log.Criticalf("%s\n%s", err_.Message, debug.Stack())
+					result = nil
+					validMethod = true
+					validParams = true
+					err = err_
+				}
+			}()
+
+			return next.Handle(context)
+		})
+	}
+}
+
+// Only the listed methods will be handled. Other methods are treated as
+// unsupported. The lifecycle methods ("initialize", "initialized", "shutdown"
+// and "exit") are always handled, because without them the connection is
+// unusable.
+func AllowMethodsMiddleware(methods ...string) glsp.Middleware {
+	return methodsMiddleware(methods, true)
+}
+
+// The listed methods are treated as unsupported. The lifecycle methods
+// ("initialize", "initialized", "shutdown" and "exit") are always handled, even
+// if listed.
+func DenyMethodsMiddleware(methods ...string) glsp.Middleware {
+	return methodsMiddleware(methods, false)
+}
+
+func methodsMiddleware(methods []string, allow bool) glsp.Middleware {
+	methods_ := make(map[string]struct{})
+	for _, method := range methods {
+		methods_[method] = struct{}{}
+	}
+
+	return func(next glsp.Handler) glsp.Handler {
+		return glsp.HandlerFunc(func(context *glsp.Context) (any, bool, bool, error) {
+			if _, ok := methods_[context.Method]; (ok == allow) || isLifecycleMethod(context.Method) {
+				return next.Handle(context)
+			} else {
+				return nil, false, false, nil
+			}
+		})
+	}
+}
+
+func isLifecycleMethod(method string) bool {
+	switch method {
+	case methodInitialize, methodInitialized, methodShutdown, methodExit:
+		return true
+	default:
+		return false
+	}
+}
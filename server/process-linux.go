@@ -0,0 +1,30 @@
+//go:build linux
+
+package server
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const processWatchSupported = true
+
+func processExists(pid int) bool {
+	if err := syscall.Kill(pid, 0); errors.Is(err, syscall.ESRCH) {
+		return false
+	}
+
+	// A zombie process has exited but was not yet reaped by its parent
+	if stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat"); err == nil {
+		// The state follows the command name, which is in parentheses and
+		// may itself contain parentheses
+		if index := strings.LastIndexByte(string(stat), ')'); (index != -1) && (index+2 < len(stat)) {
+			return stat[index+2] != 'Z'
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package server
+
+const processWatchSupported = false
+
+func processExists(pid int) bool {
+	return true
+}
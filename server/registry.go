@@ -0,0 +1,165 @@
+package server
+
+import (
+	contextpkg "context"
+	"encoding/json"
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/tliron/glsp"
+)
+
+//
+// Connection
+//
+
+// An active connection to a client. Use it to send messages to the client
+// outside of handling a message, e.g. from background goroutines. See
+// [Server.Connections] and [ContextConnection].
+type Connection struct {
+	ID            uint64
+	RemoteAddress string // empty if not a network connection
+	Connected     time.Time
+	Session       *glsp.Session
+
+	connection    atomic.Pointer[jsonrpc2.Conn]
+	clientName    string
+	clientVersion string
+	lock          sync.RWMutex
+}
+
+type connectionKey struct{}
+
+// Returns the connection of the context's session, or nil if there is none.
+func ContextConnection(context *glsp.Context) *Connection {
+	if context.Session != nil {
+		if connection, ok := context.Session.Get(connectionKey{}); ok {
+			return connection.(*Connection)
+		}
+	}
+	return nil
+}
+
+func (self *Server) newConnection(session *glsp.Session) *Connection {
+	connection := Connection{
+		ID:        self.nextConnectionID.Add(1),
+		Connected: time.Now(),
+		Session:   session,
+	}
+	session.Set(connectionKey{}, &connection)
+	return &connection
+}
+
+// The client name and version from "initialize". Both are empty if not
+// provided by the client.
+func (self *Connection) GetClientInfo() (string, string) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.clientName, self.clientVersion
+}
+
+func (self *Connection) setClientInfo(request *jsonrpc2.Request) {
+	if request.Params == nil {
+		return
+	}
+
+	var params struct {
+		ClientInfo *struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"clientInfo"`
+	}
+	if err := json.Unmarshal(*request.Params, &params); (err == nil) && (params.ClientInfo != nil) {
+		self.lock.Lock()
+		defer self.lock.Unlock()
+		self.clientName = params.ClientInfo.Name
+		self.clientVersion = params.ClientInfo.Version
+	}
+}
+
+func (self *Connection) Notify(context contextpkg.Context, method string, params any) error {
+	if connection := self.connection.Load(); connection != nil {
+		return connection.Notify(context, method, params)
+	} else {
+		return jsonrpc2.ErrClosed
+	}
+}
+
+// Errors returned by the client will be of type *glsp.Error.
+func (self *Connection) Call(context contextpkg.Context, method string, params any, result any) error {
+	if connection := self.connection.Load(); connection != nil {
+		return call(context, connection, method, params, result)
+	} else {
+		return jsonrpc2.ErrClosed
+	}
+}
+
+func call(context contextpkg.Context, connection *jsonrpc2.Conn, method string, params any, result any) error {
+	if err := connection.Call(context, method, params, result); err != nil {
+		if err_, ok := err.(*jsonrpc2.Error); ok {
+			return newError(err_)
+		} else {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the active connections ordered by ID.
+func (self *Server) Connections() []*Connection {
+	self.lifecycleLock.Lock()
+	connections := make([]*Connection, 0, len(self.connections))
+	for _, dispatcher := range self.connections {
+		connections = append(connections, dispatcher.connection)
+	}
+	self.lifecycleLock.Unlock()
+
+	slices.SortFunc(connections, func(a *Connection, b *Connection) int {
+		if a.ID < b.ID {
+			return -1
+		} else if a.ID > b.ID {
+			return 1
+		}
+		return 0
+	})
+	return connections
+}
+
+func (self *Server) GetConnection(id uint64) (*Connection, bool) {
+	self.lifecycleLock.Lock()
+	defer self.lifecycleLock.Unlock()
+
+	for _, dispatcher := range self.connections {
+		if dispatcher.connection.ID == id {
+			return dispatcher.connection, true
+		}
+	}
+	return nil, false
+}
+
+// Sends the notification to all connections with initialized sessions.
+func (self *Server) Broadcast(context contextpkg.Context, method string, params any) error {
+	var errs []error
+	for _, connection := range self.Connections() {
+		if connection.Session.IsInitialized() {
+			if err := connection.Notify(context, method, params); (err != nil) && (err != jsonrpc2.ErrClosed) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func remoteAddress(stream any) string {
+	if stream_, ok := stream.(interface{ RemoteAddr() net.Addr }); ok {
+		if address := stream_.RemoteAddr(); address != nil {
+			return address.String()
+		}
+	}
+	return ""
+}
@@ -1,12 +1,22 @@
 package server
 
 import (
+	contextpkg "context"
 	"errors"
 	"os"
 	"strconv"
 )
 
 func (self *Server) RunNodeJs() error {
+	return self.RunNodeJsContext(contextpkg.Background())
+}
+
+// The server is shut down when the context is done.
+func (self *Server) RunNodeJsContext(context contextpkg.Context) error {
+	if self.isClosed() {
+		return ErrServerClosed
+	}
+
 	nodeChannelFd := os.Getenv("NODE_CHANNEL_FD")
 	if len(nodeChannelFd) == 0 {
 		return errors.New("NODE_CHANNEL_FD not in environment")
@@ -17,6 +27,8 @@ func (self *Server) RunNodeJs() error {
 	}
 	file := os.NewFile(uintptr(nodeChannelFdInt), "/glsp/NODE_CHANNEL_FD")
 
+	defer self.shutdownWhenDone(context)()
+
 	self.Log.Notice("listening for Node.js IPC connections")
 	self.ServeStream(file, nil)
 	return nil
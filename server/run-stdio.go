@@ -1,11 +1,22 @@
 package server
 
 import (
+	contextpkg "context"
 	"errors"
 	"os"
 )
 
 func (self *Server) RunStdio() error {
+	return self.RunStdioContext(contextpkg.Background())
+}
+
+// The server is shut down when the context is done.
+func (self *Server) RunStdioContext(context contextpkg.Context) error {
+	if self.isClosed() {
+		return ErrServerClosed
+	}
+	defer self.shutdownWhenDone(context)()
+
 	self.Log.Notice("reading from stdin, writing to stdout")
 	self.ServeStream(Stdio{}, nil)
 	return nil
@@ -1,30 +1,59 @@
 package server
 
 import (
+	contextpkg "context"
+
 	"github.com/tliron/commonlog"
 )
 
 func (self *Server) RunTCP(address string) error {
-	listener, err := self.newNetworkListener("tcp", address)
+	return self.RunTCPContext(contextpkg.Background(), address)
+}
+
+// The server is shut down when the context is done.
+func (self *Server) RunTCPContext(context contextpkg.Context, address string) error {
+	return self.runListener(context, "tcp", address, "listening for TCP connections")
+}
+
+func (self *Server) runListener(context contextpkg.Context, network string, address string, message string) error {
+	listener, err := self.newNetworkListener(network, address)
 	if err != nil {
 		return err
 	}
 
 	log := commonlog.NewKeyValueLogger(self.Log, "address", address)
-	defer commonlog.CallAndLogError((*listener).Close, "listener.Close", log)
-	log.Notice("listening for TCP connections")
+	if !self.trackCloser(*listener) {
+		commonlog.CallAndLogError((*listener).Close, "listener.Close", log)
+		return ErrServerClosed
+	}
+	defer self.closeListener(*listener, log)
+	defer self.shutdownWhenDone(context)()
+
+	log.Notice(message)
 
 	var connectionCount uint64
 
 	for {
 		connection, err := (*listener).Accept()
 		if err != nil {
+			if self.waitForShutdown() {
+				return nil
+			}
 			return err
 		}
 
+		if !self.acquireConnection() {
+			log.Warningf("rejecting connection from %s: too many connections", connection.RemoteAddr())
+			commonlog.CallAndLogError(connection.Close, "connection.Close", log)
+			continue
+		}
+
 		connectionCount++
 		connectionLog := commonlog.NewKeyValueLogger(log, "id", connectionCount)
 
-		go self.ServeStream(connection, connectionLog)
+		go func() {
+			defer self.releaseConnection()
+			self.serveStream(connection, connectionLog, true)
+		}()
 	}
 }
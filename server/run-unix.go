@@ -0,0 +1,43 @@
+package server
+
+import (
+	contextpkg "context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+)
+
+// Listens on a Unix domain socket at the path. A stale socket file left
+// behind by a previous run will be removed, but not a socket that is in use.
+func (self *Server) RunUnix(path string) error {
+	return self.RunUnixContext(contextpkg.Background(), path)
+}
+
+// The server is shut down when the context is done.
+func (self *Server) RunUnixContext(context contextpkg.Context, path string) error {
+	if err := removeStaleSocket(path); err != nil {
+		return err
+	}
+	return self.runListener(context, "unix", path, "listening for Unix domain socket connections")
+}
+
+func removeStaleSocket(path string) error {
+	if info, err := os.Stat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return fmt.Errorf("not a socket: %s", path)
+		}
+
+		if connection, err := net.Dial("unix", path); err == nil {
+			connection.Close()
+			return fmt.Errorf("socket in use: %s", path)
+		}
+
+		return os.Remove(path)
+	} else if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else {
+		return err
+	}
+}
@@ -1,6 +1,8 @@
 package server
 
 import (
+	contextpkg "context"
+	"encoding/json"
 	"net/http"
 	"sync/atomic"
 
@@ -10,36 +12,149 @@ import (
 )
 
 func (self *Server) RunWebSocket(address string) error {
+	return self.RunWebSocketContext(contextpkg.Background(), address)
+}
+
+// The server is shut down when the context is done.
+func (self *Server) RunWebSocketContext(context contextpkg.Context, address string) error {
+	listener, err := self.newNetworkListener("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	server := http.Server{
+		Handler:      self.HTTPHandler(),
+		ReadTimeout:  self.ReadTimeout,
+		WriteTimeout: self.WriteTimeout,
+	}
+
+	if !self.trackCloser(&server) {
+		commonlog.CallAndLogError((*listener).Close, "listener.Close", self.Log)
+		return ErrServerClosed
+	}
+	defer self.untrackCloser(&server)
+	defer self.shutdownWhenDone(context)()
+
+	self.Log.Notice("listening for web socket connections", "address", address, "path", self.webSocketPath())
+	if err = server.Serve(*listener); self.waitForShutdown() {
+		return nil
+	}
+	return errors.Wrap(err, "WebSocket")
+}
+
+// Returns a handler that serves web socket connections at Server.WebSocketPath
+// (defaults to "/"), as well as the "/healthz" and "/readyz" endpoints. An
+// endpoint is not mounted if Server.WebSocketPath is its path. See
+// [Server.WebSocketHandler] for mounting just the web socket handler in your
+// own mux.
+func (self *Server) HTTPHandler() http.Handler {
 	mux := http.NewServeMux()
-	upgrader := websocket.Upgrader{CheckOrigin: func(request *http.Request) bool { return true }}
+	webSocketPath := self.webSocketPath()
+	mux.Handle(webSocketPath, self.WebSocketHandler())
+	if webSocketPath != "/healthz" {
+		mux.Handle("/healthz", self.HealthHandler())
+	}
+	if webSocketPath != "/readyz" {
+		mux.Handle("/readyz", self.ReadinessHandler())
+	}
+	return mux
+}
+
+// Returns a handler that upgrades HTTP requests to web socket connections and
+// serves them. It is subject to Server.AllowedOrigins,
+// Server.AuthenticateWebSocket and the limits for accepted connections.
+//
+// Note that it must not be wrapped with [http.TimeoutHandler] or any other
+// handler that does not support [http.Hijacker].
+func (self *Server) WebSocketHandler() http.Handler {
+	upgrader := websocket.Upgrader{
+		HandshakeTimeout: self.WebSocketTimeout,
+		CheckOrigin:      self.checkOrigin,
+	}
 
 	var connectionCount uint64
 
-	mux.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if self.AuthenticateWebSocket != nil {
+			if err := self.AuthenticateWebSocket(request); err != nil {
+				self.Log.Warningf("rejecting web socket connection from %s: %s", request.RemoteAddr, err.Error())
+				http.Error(writer, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		if self.isClosed() {
+			http.Error(writer, ErrServerClosed.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		if !self.acquireConnection() {
+			self.Log.Warningf("rejecting web socket connection from %s: too many connections", request.RemoteAddr)
+			http.Error(writer, "too many connections", http.StatusServiceUnavailable)
+			return
+		}
+		defer self.releaseConnection()
+
 		connection, err := upgrader.Upgrade(writer, request, nil)
 		if err != nil {
+			// Upgrade has already replied with an HTTP error
 			self.Log.Warningf("error upgrading HTTP to web socket: %s", err.Error())
-			http.Error(writer, errors.Wrap(err, "could not upgrade to web socket").Error(), http.StatusBadRequest)
 			return
 		}
 
 		log := commonlog.NewKeyValueLogger(self.Log, "id", atomic.AddUint64(&connectionCount, 1))
 		defer commonlog.CallAndLogError(connection.Close, "connection.Close", log)
-		self.ServeWebSocket(connection, log)
+		self.serveWebSocket(connection, log, true)
 	})
+}
 
-	listener, err := self.newNetworkListener("tcp", address)
-	if err != nil {
-		return err
+// Always responds with HTTP status 200 and the number of connections while the
+// server is running.
+func (self *Server) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		self.writeStatus(writer, "ok", http.StatusOK)
+	})
+}
+
+// Responds with HTTP status 200 and the number of connections if the server
+// can accept new connections, or 503 if it is shutting down or has reached
+// Server.MaxConnections.
+func (self *Server) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if self.isClosed() {
+			self.writeStatus(writer, "shutting down", http.StatusServiceUnavailable)
+		} else if (self.MaxConnections > 0) && (self.activeConnections.Load() >= int64(self.MaxConnections)) {
+			self.writeStatus(writer, "too many connections", http.StatusServiceUnavailable)
+		} else {
+			self.writeStatus(writer, "ready", http.StatusOK)
+		}
+	})
+}
+
+func (self *Server) writeStatus(writer http.ResponseWriter, status string, code int) {
+	connections := self.Connections()
+	initialized := 0
+	for _, connection := range connections {
+		if connection.Session.IsInitialized() {
+			initialized++
+		}
 	}
 
-	server := http.Server{
-		Handler:      http.TimeoutHandler(mux, self.Timeout, ""),
-		ReadTimeout:  self.ReadTimeout,
-		WriteTimeout: self.WriteTimeout,
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(code)
+	if err := json.NewEncoder(writer).Encode(map[string]any{
+		"status":                 status,
+		"connections":            len(connections),
+		"initializedConnections": initialized,
+	}); err != nil {
+		self.Log.Errorf("could not write status: %s", err.Error())
 	}
+}
 
-	self.Log.Notice("listening for web socket connections", "address", address)
-	err = server.Serve(*listener)
-	return errors.Wrap(err, "WebSocket")
+func (self *Server) webSocketPath() string {
+	if self.WebSocketPath != "" {
+		return self.WebSocketPath
+	} else {
+		return "/"
+	}
 }
@@ -10,19 +10,28 @@ import (
 // See: https://github.com/sourcegraph/go-langserver/blob/master/main.go#L179
 
 func (self *Server) ServeStream(stream io.ReadWriteCloser, log commonlog.Logger) {
+	self.serveStream(stream, log, false)
+}
+
+func (self *Server) ServeWebSocket(socket *websocket.Conn, log commonlog.Logger) {
+	self.serveWebSocket(socket, log, false)
+}
+
+// Accepted connections are those accepted by one of our listeners
+func (self *Server) serveStream(stream io.ReadWriteCloser, log commonlog.Logger, accepted bool) {
 	if log == nil {
 		log = self.Log
 	}
 	log.Info("new stream connection")
-	<-self.newStreamConnection(stream).DisconnectNotify()
+	self.serve(self.newStreamConnection(stream, accepted))
 	log.Info("stream connection closed")
 }
 
-func (self *Server) ServeWebSocket(socket *websocket.Conn, log commonlog.Logger) {
+func (self *Server) serveWebSocket(socket *websocket.Conn, log commonlog.Logger, accepted bool) {
 	if log == nil {
 		log = self.Log
 	}
 	log.Info("new web socket connection")
-	<-self.newWebSocketConnection(socket).DisconnectNotify()
+	self.serve(self.newWebSocketConnection(socket, accepted))
 	log.Info("web socket connection closed")
 }
@@ -1,20 +1,41 @@
 package server
 
 import (
+	contextpkg "context"
+	"crypto/tls"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/sourcegraph/jsonrpc2"
 	"github.com/tliron/commonlog"
 	"github.com/tliron/glsp"
 )
 
 var DefaultTimeout = time.Minute
 
+// Used when Server.MaxConcurrentRequests is 0
+var DefaultMaxConcurrentRequests = max(runtime.NumCPU(), 4)
+
+// Used when Server.BarrierMethods is nil. These are the standard requests that
+// may change state.
+var DefaultBarrierMethods = []string{
+	"workspace/executeCommand",
+	"workspace/willCreateFiles",
+	"workspace/willRenameFiles",
+	"workspace/willDeleteFiles",
+	"textDocument/willSaveWaitUntil",
+}
+
 //
 // Server
 //
 
 type Server struct {
 	Handler     glsp.Handler
+	Middlewares []glsp.Middleware // the first is the outermost, see glsp.Chain
 	LogBaseName string
 	Debug       bool
 
@@ -24,6 +45,85 @@ type Server struct {
 	WriteTimeout     time.Duration
 	StreamTimeout    time.Duration
 	WebSocketTimeout time.Duration
+
+	// TLS for TCP and WebSocket listeners. In order of precedence: TLSConfig
+	// (cloned) if not nil, else TLSCertFile and TLSKeyFile (reloaded when they
+	// change) if either is set, else PEM text in the TLS_CERT and TLS_KEY
+	// environment variables if both are set. Otherwise TLS is not used.
+	//
+	// TLSClientCAFile enables mutual TLS and requires one of the above. Note
+	// that it overwrites ClientAuth and ClientCAs even when TLSConfig is
+	// provided (but only in the clone).
+	TLSConfig       *tls.Config
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+
+	// The path at which web socket connections are served, defaults to "/".
+	// If it is "/healthz" or "/readyz" then that endpoint is not served. See
+	// Server.HTTPHandler.
+	WebSocketPath string
+
+	// Web socket origins (scheme and host) that are allowed to connect, where
+	// "*" allows all. Empty allows all.
+	AllowedOrigins []string
+
+	// Authentication for web socket connections, before they are upgraded
+	AuthenticateWebSocket AuthenticateWebSocketFunc
+
+	// Authentication for connections accepted by TCP, Unix domain socket and
+	// web socket listeners. The first message must be "initialize" and it is
+	// checked before any handler runs.
+	AuthenticateInitialize AuthenticateInitializeFunc
+
+	// Limits for connections accepted by TCP, Unix domain socket and web
+	// socket listeners: additional connections are rejected, and connections
+	// that are idle (no messages received and none pending) are closed. 0
+	// means no limit.
+	MaxConnections int
+	IdleTimeout    time.Duration
+
+	// Limits for all connections. Connections that send larger messages (in
+	// bytes) are closed, and requests beyond the number already queued or
+	// being handled are rejected. 0 means no limit.
+	MaxMessageSize    int64
+	MaxQueuedRequests int
+
+	// Sends "window/showMessage" to the client when a handler panics, so that
+	// users know that a feature failed
+	ShowPanicMessages bool
+
+	// Exits the process when the client sends "exit", which is what the spec
+	// expects of a server that is started by the client
+	ExitProcess bool
+
+	// Shuts down the server when the client process is gone. Its process ID is
+	// taken from "initialize" and from the "--clientProcessId" argument. This
+	// is only meaningful if the server is started by a single client on the
+	// same machine, and is currently only supported on Linux.
+	WatchClientProcess         bool
+	WatchClientProcessInterval time.Duration
+
+	Dispatch              DispatchMode
+	MaxConcurrentRequests int // for DispatchConcurrent
+
+	// For DispatchConcurrent. These requests may change state, so they act
+	// as barriers like notifications do. When nil defaults to
+	// [DefaultBarrierMethods].
+	BarrierMethods []string
+
+	requestSlots     chan struct{}
+	requestSlotsOnce sync.Once
+
+	nextConnectionID  atomic.Uint64
+	activeConnections atomic.Int64
+
+	closed           bool
+	shutdown         chan struct{}
+	watchedProcesses map[int]struct{}
+	closers          map[io.Closer]struct{}
+	connections      map[*jsonrpc2.Conn]*dispatcher
+	lifecycleLock    sync.Mutex
 }
 
 func NewServer(handler glsp.Handler, logName string, debug bool) *Server {
@@ -39,3 +139,42 @@ func NewServer(handler glsp.Handler, logName string, debug bool) *Server {
 		WebSocketTimeout: DefaultTimeout,
 	}
 }
+
+// The request slots are shared by all connections
+func (self *Server) acquireRequestSlot(context contextpkg.Context) bool {
+	self.requestSlotsOnce.Do(func() {
+		size := self.MaxConcurrentRequests
+		if size <= 0 {
+			size = DefaultMaxConcurrentRequests
+		}
+		self.requestSlots = make(chan struct{}, size)
+	})
+
+	select {
+	case self.requestSlots <- struct{}{}:
+		return true
+	case <-context.Done():
+		return false
+	}
+}
+
+func (self *Server) releaseRequestSlot() {
+	<-self.requestSlots
+}
+
+// For connections accepted by listeners
+func (self *Server) acquireConnection() bool {
+	for {
+		active := self.activeConnections.Load()
+		if (self.MaxConnections > 0) && (active >= int64(self.MaxConnections)) {
+			return false
+		}
+		if self.activeConnections.CompareAndSwap(active, active+1) {
+			return true
+		}
+	}
+}
+
+func (self *Server) releaseConnection() {
+	self.activeConnections.Add(-1)
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	contextpkg "context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// Returned by the Run* functions when called after [Server.Shutdown].
+var ErrServerClosed = errors.New("server closed")
+
+// Stops accepting new connections, waits for in-flight requests to finish
+// while rejecting new ones, and then closes all connections. If the context
+// is done before the requests finish then the connections are closed anyway
+// and the context's error is returned.
+//
+// The Run* functions return nil after the server is shut down. A server
+// cannot be run again after it has been shut down.
+func (self *Server) Shutdown(context contextpkg.Context) error {
+	shutdown := self.shutdownDone()
+
+	self.lifecycleLock.Lock()
+	if self.closed {
+		self.lifecycleLock.Unlock()
+
+		// Wait for the shutdown already in progress
+		select {
+		case <-shutdown:
+			return nil
+		case <-context.Done():
+			return context.Err()
+		}
+	}
+	self.closed = true
+	closers := self.closers
+	connections := self.connections
+	self.closers = nil
+	self.connections = nil
+	self.lifecycleLock.Unlock()
+
+	self.Log.Notice("shutting down")
+	defer close(shutdown)
+
+	var errs []error
+	for closer := range closers {
+		if err := closer.Close(); (err != nil) && !errors.Is(err, net.ErrClosed) && !errors.Is(err, http.ErrServerClosed) {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, dispatcher := range connections {
+		dispatcher.drain()
+	}
+
+	for _, dispatcher := range connections {
+		if err := dispatcher.wait(context); err != nil {
+			errs = append(errs, err)
+			break
+		}
+	}
+
+	for connection := range connections {
+		if err := connection.Close(); (err != nil) && (err != jsonrpc2.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Closed when shutdown is complete
+func (self *Server) shutdownDone() chan struct{} {
+	self.lifecycleLock.Lock()
+	defer self.lifecycleLock.Unlock()
+	if self.shutdown == nil {
+		self.shutdown = make(chan struct{})
+	}
+	return self.shutdown
+}
+
+// Returns true after waiting for shutdown to complete, or false if the server
+// was not shut down.
+func (self *Server) waitForShutdown() bool {
+	if self.isClosed() {
+		<-self.shutdownDone()
+		return true
+	}
+	return false
+}
+
+func (self *Server) isClosed() bool {
+	self.lifecycleLock.Lock()
+	defer self.lifecycleLock.Unlock()
+	return self.closed
+}
+
+// Calls [Server.Shutdown] when the context is done, limiting it to
+// Server.Timeout.
+func (self *Server) shutdownWhenDone(context contextpkg.Context) func() bool {
+	return contextpkg.AfterFunc(context, func() {
+		shutdownContext, cancel := contextpkg.WithTimeout(contextpkg.Background(), self.Timeout)
+		defer cancel()
+		if err := self.Shutdown(shutdownContext); err != nil {
+			self.Log.Errorf("shutdown: %s", err.Error())
+		}
+	})
+}
+
+// Closers (e.g. listeners) are closed on shutdown. Returns false if the server
+// is already closed, in which case the caller is responsible for closing.
+func (self *Server) trackCloser(closer io.Closer) bool {
+	self.lifecycleLock.Lock()
+	defer self.lifecycleLock.Unlock()
+
+	if self.closed {
+		return false
+	}
+
+	if self.closers == nil {
+		self.closers = make(map[io.Closer]struct{})
+	}
+	self.closers[closer] = struct{}{}
+	return true
+}
+
+func (self *Server) untrackCloser(closer io.Closer) {
+	self.lifecycleLock.Lock()
+	defer self.lifecycleLock.Unlock()
+	delete(self.closers, closer)
+}
+
+// Blocks until the connection is closed
+func (self *Server) serve(connection *jsonrpc2.Conn, dispatcher_ *dispatcher) {
+	self.lifecycleLock.Lock()
+	if self.closed {
+		self.lifecycleLock.Unlock()
+		connection.Close()
+		return
+	}
+	if self.connections == nil {
+		self.connections = make(map[*jsonrpc2.Conn]*dispatcher)
+	}
+	dispatcher_.connection.connection.Store(connection)
+	self.connections[connection] = dispatcher_
+	self.lifecycleLock.Unlock()
+
+	if dispatcher_.accepted && (self.IdleTimeout > 0) {
+		go dispatcher_.closeWhenIdle(connection, self.IdleTimeout)
+	}
+
+	<-connection.DisconnectNotify()
+
+	self.lifecycleLock.Lock()
+	delete(self.connections, connection)
+	self.lifecycleLock.Unlock()
+}
+
+// Per the spec the exit code is 0 if "shutdown" was received before "exit",
+// otherwise 1.
+func (self *Server) exit(shutdownReceived bool) {
+	code := 1
+	if shutdownReceived {
+		code = 0
+	}
+
+	context, cancel := contextpkg.WithTimeout(contextpkg.Background(), self.Timeout)
+	defer cancel()
+	if err := self.Shutdown(context); err != nil {
+		self.Log.Errorf("shutdown: %s", err.Error())
+	}
+
+	self.Log.Noticef("exiting with code %d", code)
+	os.Exit(code)
+}
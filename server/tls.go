@@ -0,0 +1,130 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Returns nil if TLS is not configured. In order of precedence TLS is
+// configured by Server.TLSConfig, by Server.TLSCertFile and
+// Server.TLSKeyFile, or by PEM text in the TLS_CERT and TLS_KEY environment
+// variables.
+func (self *Server) newTLSConfig() (*tls.Config, error) {
+	var config *tls.Config
+
+	if self.TLSConfig != nil {
+		config = self.TLSConfig.Clone()
+	} else if (self.TLSCertFile != "") || (self.TLSKeyFile != "") {
+		reloader, err := NewCertificateReloader(self.TLSCertFile, self.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config = &tls.Config{GetCertificate: reloader.GetCertificate}
+	} else {
+		cert := os.Getenv("TLS_CERT")
+		key := os.Getenv("TLS_KEY")
+		if (cert != "") && (key != "") {
+			cert, err := tls.X509KeyPair([]byte(cert), []byte(key))
+			if err != nil {
+				return nil, err
+			}
+			config = &tls.Config{Certificates: []tls.Certificate{cert}}
+		}
+	}
+
+	if self.TLSClientCAFile != "" {
+		if config == nil {
+			return nil, fmt.Errorf("TLSClientCAFile requires a server certificate")
+		}
+
+		pem, err := os.ReadFile(self.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in TLS client CA file: %s", self.TLSClientCAFile)
+		}
+
+		config.ClientCAs = clientCAs
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+//
+// CertificateReloader
+//
+
+// Loads a certificate and key from PEM files and reloads them when the files
+// are modified, e.g. by certificate rotation, without having to restart the
+// server. If reloading fails then the previous certificate continues to be
+// used. Use [CertificateReloader.GetCertificate] in [tls.Config].
+type CertificateReloader struct {
+	CertFile string
+	KeyFile  string
+
+	certificate  *tls.Certificate
+	certModified time.Time
+	keyModified  time.Time
+	lock         sync.Mutex
+}
+
+// Will return an error if the files cannot be loaded.
+func NewCertificateReloader(certFile string, keyFile string) (*CertificateReloader, error) {
+	self := CertificateReloader{
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	}
+
+	if err := self.reload(); err == nil {
+		return &self, nil
+	} else {
+		return nil, err
+	}
+}
+
+// (For [tls.Config].GetCertificate)
+func (self *CertificateReloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if err := self.reload(); (err != nil) && (self.certificate == nil) {
+		return nil, err
+	}
+
+	return self.certificate, nil
+}
+
+// Must be called while holding the lock, except in the constructor
+func (self *CertificateReloader) reload() error {
+	certInfo, err := os.Stat(self.CertFile)
+	if err != nil {
+		return err
+	}
+
+	keyInfo, err := os.Stat(self.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	if (self.certificate != nil) && certInfo.ModTime().Equal(self.certModified) && keyInfo.ModTime().Equal(self.keyModified) {
+		return nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(self.CertFile, self.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	self.certificate = &certificate
+	self.certModified = certInfo.ModTime()
+	self.keyModified = keyInfo.ModTime()
+	return nil
+}
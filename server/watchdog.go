@@ -0,0 +1,68 @@
+package server
+
+import (
+	contextpkg "context"
+	"time"
+)
+
+// Used when Server.WatchClientProcessInterval is 0
+var DefaultWatchClientProcessInterval = 3 * time.Second
+
+// Starts polling the client process, shutting down the server when it is gone.
+// Watching the same process again has no effect.
+func (self *Server) watchClientProcess(pid int) {
+	if !self.WatchClientProcess || (pid <= 0) {
+		return
+	}
+
+	if !processWatchSupported {
+		self.Log.Warning("watching the client process is not supported on this platform")
+		return
+	}
+
+	self.lifecycleLock.Lock()
+	if self.closed {
+		self.lifecycleLock.Unlock()
+		return
+	}
+	if self.watchedProcesses == nil {
+		self.watchedProcesses = make(map[int]struct{})
+	}
+	if _, ok := self.watchedProcesses[pid]; ok {
+		self.lifecycleLock.Unlock()
+		return
+	}
+	self.watchedProcesses[pid] = struct{}{}
+	self.lifecycleLock.Unlock()
+
+	interval := self.WatchClientProcessInterval
+	if interval <= 0 {
+		interval = DefaultWatchClientProcessInterval
+	}
+
+	self.Log.Infof("watching client process %d", pid)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		shutdown := self.shutdownDone()
+		for {
+			select {
+			case <-ticker.C:
+				if !processExists(pid) {
+					self.Log.Noticef("client process %d is gone", pid)
+					context, cancel := contextpkg.WithTimeout(contextpkg.Background(), self.Timeout)
+					if err := self.Shutdown(context); err != nil {
+						self.Log.Errorf("shutdown: %s", err.Error())
+					}
+					cancel()
+					return
+				}
+
+			case <-shutdown:
+				return
+			}
+		}
+	}()
+}
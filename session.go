@@ -0,0 +1,113 @@
+package glsp
+
+import (
+	"sync"
+)
+
+//
+// Session
+//
+
+// Per-connection state. The server creates a new session for every client
+// connection, so that clients sharing a [Handler] do not affect each other's
+// initialization state, trace level, or capabilities.
+type Session struct {
+	initialized        bool
+	trace              string
+	positionEncoding   string
+	clientCapabilities any
+	data               map[any]any
+	lock               sync.RWMutex
+}
+
+func NewSession() *Session {
+	return &Session{
+		data: make(map[any]any),
+	}
+}
+
+func (self *Session) IsInitialized() bool {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.initialized
+}
+
+func (self *Session) SetInitialized(initialized bool) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.initialized = initialized
+}
+
+// Empty string means the trace value has not been set.
+func (self *Session) GetTrace() string {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.trace
+}
+
+func (self *Session) SetTrace(trace string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.trace = trace
+}
+
+// Empty string means the default, which is "utf-16".
+func (self *Session) GetPositionEncoding() string {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.positionEncoding
+}
+
+func (self *Session) SetPositionEncoding(positionEncoding string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.positionEncoding = positionEncoding
+}
+
+// The capabilities sent by the client in the "initialize" request. The concrete
+// type depends on the protocol version, e.g. *protocol_3_16.ClientCapabilities.
+// Will be nil before initialization.
+func (self *Session) GetClientCapabilities() any {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.clientCapabilities
+}
+
+func (self *Session) SetClientCapabilities(clientCapabilities any) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.clientCapabilities = clientCapabilities
+}
+
+// Arbitrary user data
+
+func (self *Session) Get(key any) (any, bool) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	value, ok := self.data[key]
+	return value, ok
+}
+
+func (self *Session) Set(key any, value any) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.data[key] = value
+}
+
+// Atomically returns the existing value or sets and returns a new one.
+func (self *Session) GetOrCreate(key any, create func() any) any {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if value, ok := self.data[key]; ok {
+		return value
+	}
+	value := create()
+	self.data[key] = value
+	return value
+}
+
+func (self *Session) Delete(key any) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	delete(self.data, key)
+}